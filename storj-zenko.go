@@ -3,15 +3,19 @@ package main
 import (
 	//Standard Packages
 
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"sort"
 	"time"
+	"utropicmedia/zenko_storj_interface/backup"
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/retry"
 	"utropicmedia/zenko_storj_interface/storj"
 	"utropicmedia/zenko_storj_interface/zenko"
 
@@ -37,10 +41,323 @@ func setAppInfo() {
 
 // helper function to flag debug
 func setDebug(debugVal bool) {
-	gbDEBUG = true
+	gbDEBUG = debugVal
 	storj.DEBUG = debugVal
 }
 
+// connection names the configuration files a command reads and how it accesses Storj.
+type connection struct {
+	zenkoConfig string
+	storjConfig string
+	// deriveScope derives the Storj scope from the API key and the encryption passphrase
+	// instead of reading the serialized scope of the Storj configuration.
+	deriveScope bool
+	// restrict restricts the derived scope to the bucket and the upload path.
+	restrict bool
+}
+
+// connectionFlags returns the flags naming the configuration files of a command, whether it
+// reads the Zenko configuration, the Storj configuration or both, and the --debug flag.
+// Every flag falls back to an environment variable.
+func connectionFlags(withZenko bool, withStorj bool) []cli.Flag {
+	var flags []cli.Flag
+	if withZenko {
+		flags = append(flags, &cli.StringFlag{
+			Name:    "zenko-config",
+			Value:   zenkoConfigFile,
+			EnvVars: []string{"ZENKO_CONFIG"},
+			Usage:   "read the Zenko (S3 source) configuration from the JSON `FILE`",
+		})
+	}
+	if withStorj {
+		flags = append(flags,
+			&cli.StringFlag{
+				Name:    "storj-config",
+				Value:   storjConfigFile,
+				EnvVars: []string{"STORJ_CONFIG"},
+				Usage:   "read the Storj configuration from the JSON `FILE`",
+			},
+			&cli.BoolFlag{
+				Name:    "derive-scope",
+				EnvVars: []string{"STORJ_DERIVE_SCOPE"},
+				Usage:   "derive the scope from the API key and the encryption passphrase of the Storj configuration instead of using its serialized scope, and print it",
+			},
+			&cli.BoolFlag{
+				Name:    "restrict",
+				EnvVars: []string{"STORJ_RESTRICT"},
+				Usage:   "restrict the derived scope to the bucket, the upload path and the disallow settings of the Storj configuration (implies --derive-scope)",
+			},
+		)
+	}
+	return append(flags, &cli.BoolFlag{
+		Name:    "debug",
+		EnvVars: []string{"STORJ_ZENKO_DEBUG"},
+		Usage:   "print more details of the work done",
+	})
+}
+
+// connectionArgsUsage describes the positional arguments parseConnection still accepts.
+func connectionArgsUsage(withZenko bool, withStorj bool) string {
+	usage := "[storj-config [key [restrict]]]"
+	switch {
+	case withZenko && withStorj:
+		usage = "[zenko-config " + usage + "]"
+	case withZenko:
+		usage = "[zenko-config]"
+	}
+	return "[debug] " + usage + " (deprecated, use the flags)"
+}
+
+// parseConnection reads the connection flags of a command and switches debug mode on when
+// --debug is set. Earlier versions took the same settings as positional arguments: the
+// configuration files in order, "key" for --derive-scope followed by "restrict" for --restrict,
+// and "debug" anywhere. They are still read, with a warning, for the flags that are not set.
+// As before, any other word in the place of "key" or "restrict" leaves the scope as it is and
+// "restrict" only counts after "key". Every argument after "key" takes the place of "restrict",
+// so the last one decides like it did; further arguments of commands without Storj are ignored.
+func parseConnection(cliContext *cli.Context, withZenko bool, withStorj bool) (connection, error) {
+	var conn connection
+	if withZenko {
+		conn.zenkoConfig = cliContext.String("zenko-config")
+	}
+	if withStorj {
+		conn.storjConfig = cliContext.String("storj-config")
+		conn.restrict = cliContext.Bool("restrict")
+		conn.deriveScope = cliContext.Bool("derive-scope") || conn.restrict
+	}
+	debug := cliContext.Bool("debug")
+
+	args := cliContext.Args().Slice()
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: positional arguments are deprecated, use --zenko-config, --storj-config, --derive-scope, --restrict and --debug instead.")
+	}
+	var positions []string
+	if withZenko {
+		positions = append(positions, "zenko-config")
+	}
+	if withStorj {
+		positions = append(positions, "storj-config", "key", "restrict")
+	}
+	var key, restrict bool
+	for _, arg := range args {
+		if arg == "debug" {
+			debug = true
+			continue
+		}
+		if len(positions) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring argument %q.\n", arg)
+			continue
+		}
+		position := positions[0]
+		if position != "restrict" {
+			positions = positions[1:]
+		}
+		switch position {
+		case "zenko-config", "storj-config":
+			if cliContext.IsSet(position) {
+				return conn, fmt.Errorf("argument %q repeats --%s, use only the flag", arg, position)
+			}
+			if position == "zenko-config" {
+				conn.zenkoConfig = arg
+			} else {
+				conn.storjConfig = arg
+			}
+		case "key":
+			key = arg == "key"
+			if key {
+				conn.deriveScope = true
+			}
+		case "restrict":
+			restrict = arg == "restrict"
+		}
+	}
+	if key && restrict {
+		conn.restrict = true
+	}
+	if debug {
+		setDebug(true)
+	}
+	return conn, nil
+}
+
+// printScope prints the scope a command derived with --derive-scope.
+func printScope(conn connection, scope string) {
+	if !conn.deriveScope {
+		return
+	}
+	if conn.restrict {
+		fmt.Println("Restricted Serialized Scope Key: ", scope)
+	} else {
+		fmt.Println("Serialized Scope Key: ", scope)
+	}
+}
+
+// retryFlags returns the flags configuring how failed Zenko and Storj calls are retried.
+func retryFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "retries",
+			Value: retry.DefaultAttempts,
+			Usage: "maximum number of attempts of every Zenko and Storj call, 1 disables retries",
+		},
+		&cli.DurationFlag{
+			Name:  "retry-backoff",
+			Value: retry.DefaultBackoff,
+			Usage: "wait before the first retry, doubled with every further retry",
+		},
+		&cli.DurationFlag{
+			Name:  "retry-max-backoff",
+			Value: retry.DefaultMaxBackoff,
+			Usage: "maximum wait between two retries",
+		},
+		&cli.Float64Flag{
+			Name:  "retry-jitter",
+			Value: retry.DefaultJitter,
+			Usage: "randomize every wait by up to this `FRACTION` of it",
+		},
+	}
+}
+
+// retryPolicy returns the retry policy set by the retry flags.
+func retryPolicy(cliContext *cli.Context) (*retry.Policy, error) {
+	policy := retry.New(cliContext.Int("retries"), cliContext.Duration("retry-backoff"), cliContext.Duration("retry-max-backoff"), cliContext.Float64("retry-jitter"))
+	if policy.Attempts < 1 {
+		return nil, fmt.Errorf("--retries must be at least 1, got %d", policy.Attempts)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return nil, fmt.Errorf("--retry-jitter must be between 0 and 1, got %g", policy.Jitter)
+	}
+	return policy, nil
+}
+
+// filterFlags returns the flags selecting the Zenko buckets and keys a command reads.
+// They add to the rules in the "filter" section of the Zenko configuration file.
+func filterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "include-bucket",
+			Usage: "only read buckets matching the `PATTERN`, e.g. logs-*",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-bucket",
+			Usage: "skip buckets matching the `PATTERN`",
+		},
+		&cli.StringSliceFlag{
+			Name:  "include-prefix",
+			Usage: "only read keys starting with `PREFIX`",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-prefix",
+			Usage: "skip keys starting with `PREFIX`, without listing them",
+		},
+		&cli.StringSliceFlag{
+			Name:  "include-glob",
+			Usage: "only read keys matching the `GLOB`, e.g. **/*.jpg",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-glob",
+			Usage: "skip keys matching the `GLOB`",
+		},
+		&cli.StringSliceFlag{
+			Name:  "include-regex",
+			Usage: "only read keys matching the regular expression `REGEX`",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-regex",
+			Usage: "skip keys matching the regular expression `REGEX`",
+		},
+		&cli.StringFlag{
+			Name:  "modified-after",
+			Usage: "only read objects modified at or after `TIME`: 2006-01-02, 2006-01-02T15:04:05Z07:00 or an age such as 36h or 30d",
+		},
+		&cli.StringFlag{
+			Name:  "modified-before",
+			Usage: "only read objects modified before `TIME`",
+		},
+		&cli.StringFlag{
+			Name:  "min-size",
+			Usage: "only read objects of at least `SIZE`, e.g. 1KiB",
+		},
+		&cli.StringFlag{
+			Name:  "max-size",
+			Usage: "only read objects of at most `SIZE`, e.g. 10GiB",
+		},
+	}
+}
+
+// filterConfig returns the rules of the configuration file with those of the filter flags added.
+func filterConfig(cliContext *cli.Context, config filter.Config) (filter.Config, error) {
+	flags := filter.Config{
+		Include: filter.Rules{
+			Buckets:  cliContext.StringSlice("include-bucket"),
+			Prefixes: cliContext.StringSlice("include-prefix"),
+			Globs:    cliContext.StringSlice("include-glob"),
+			Regexes:  cliContext.StringSlice("include-regex"),
+		},
+		Exclude: filter.Rules{
+			Buckets:  cliContext.StringSlice("exclude-bucket"),
+			Prefixes: cliContext.StringSlice("exclude-prefix"),
+			Globs:    cliContext.StringSlice("exclude-glob"),
+			Regexes:  cliContext.StringSlice("exclude-regex"),
+		},
+	}
+
+	now := time.Now()
+	for _, limit := range []struct {
+		name  string
+		value **time.Time
+	}{
+		{"modified-after", &flags.ModifiedAfter},
+		{"modified-before", &flags.ModifiedBefore},
+	} {
+		if !cliContext.IsSet(limit.name) {
+			continue
+		}
+		t, err := filter.ParseTime(cliContext.String(limit.name), now)
+		if err != nil {
+			return config, fmt.Errorf("--%s: %v", limit.name, err)
+		}
+		*limit.value = &t
+	}
+	for _, limit := range []struct {
+		name  string
+		value *int64
+	}{
+		{"min-size", &flags.MinSize},
+		{"max-size", &flags.MaxSize},
+	} {
+		if !cliContext.IsSet(limit.name) {
+			continue
+		}
+		size, err := chunker.ParseSize(cliContext.String(limit.name))
+		if err != nil {
+			return config, fmt.Errorf("--%s: %v", limit.name, err)
+		}
+		*limit.value = size
+	}
+	return config.Merge(flags), nil
+}
+
+// printFailures prints the objects a run could not copy.
+func printFailures(failures []storj.Failure) {
+	fmt.Printf("\n%d objects could not be copied:\n", len(failures))
+	for _, failure := range failures {
+		fmt.Printf("%s\t%s\t%s\n", storj.ObjectID(failure.Bucket, failure.Key, failure.VersionID), failure.Stage, failure.Error)
+	}
+}
+
+// writeFailureReport writes the objects a run could not copy to fileName as JSON.
+func writeFailureReport(fileName string, failures []storj.Failure) error {
+	if failures == nil {
+		failures = []storj.Failure{}
+	}
+	data, err := json.MarshalIndent(failures, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
 // setCommands sets various command-line options for the app.
 func setCommands() {
 
@@ -49,36 +366,32 @@ func setCommands() {
 			Name:    "parse",
 			Aliases: []string{"p"},
 			Usage:   "Command to read and parse JSON information about Zenko instance properties and then generate a list of all files with their corresponding paths",
-			//\narguments-\n\t  fileName [optional] = provide full file name (with complete path), storing Zenko properties
-			// if this fileName is not given, then data is read from ./config/zenko_property.json\n\t
-			// example = ./storj-zenko p ./config/zenko_property.json\n",
+			// flags- --zenko-config FILE (default ./config/zenko_property.json)
+			// example = ./storj-zenko p --zenko-config ./config/zenko_property.json
+			ArgsUsage: connectionArgsUsage(true, false),
+			Flags:     append(connectionFlags(true, false), filterFlags()...),
 			Action: func(cliContext *cli.Context) error {
-				var fullFileName = zenkoConfigFile
-				// process arguments
-				if len(cliContext.Args().Slice()) > 0 {
-					for i := 0; i < len(cliContext.Args().Slice()); i++ {
-						// In case, debug is provided as argument.
-						if cliContext.Args().Slice()[i] == "debug" {
-							setDebug(true)
-						} else {
-							fullFileName = cliContext.Args().Slice()[i]
-						}
-					}
+				conn, err := parseConnection(cliContext, true, false)
+				if err != nil {
+					return err
 				}
 
 				// Establish connection with Zenko and get io.Reader implementor.
-				zenkoReader, err := zenko.ConnectToZenko(fullFileName)
+				zenkoReader, err := zenko.ConnectToZenko(conn.zenkoConfig)
 				if err != nil {
 					log.Fatalf("Failed to establish connection with Zenko: %s\n", err)
 				}
 
-				// Create a done channel to control 'ListObjects' go routine.
-				doneCh := make(chan struct{})
-
-				// Indicate to routine to exit cleanly upon return.
-				defer close(doneCh)
+				// Select buckets and keys.
+				filterRules, err := filterConfig(cliContext, zenkoReader.Config.Filter)
+				if err != nil {
+					return err
+				}
+				keys, err := filter.New(filterRules)
+				if err != nil {
+					return err
+				}
 
-				isRecursive := true
 				// List all buckets from Zenko Orbit.
 				buckets, err := zenkoReader.Client.ListBuckets()
 				if err != nil {
@@ -89,14 +402,17 @@ func setCommands() {
 				fmt.Println("Successfully connected to Zenko!")
 
 				for _, bucket := range buckets {
+					if !keys.Bucket(bucket.Name) {
+						continue
+					}
 					fmt.Printf("\n\nReading All files from the Zenko Orbit Bucket %s...\n", bucket.Name)
-					// ListObjects lists all objects from the specified bucket.
-					objectCh := zenkoReader.Client.ListObjects(bucket.Name, "", isRecursive, doneCh)
-					for object := range objectCh {
-						if object.Err != nil {
-							log.Fatal("Object Information Error: ", object.Err)
-						}
+					// ListObjects lists the selected objects from the specified bucket.
+					err = zenko.ListObjects(context.Background(), zenkoReader.Client, bucket.Name, keys, nil, func(object minio.ObjectInfo) error {
 						fmt.Println(object.Key)
+						return nil
+					})
+					if err != nil {
+						log.Fatal("Object Information Error: ", err)
 					}
 				}
 
@@ -109,42 +425,15 @@ func setCommands() {
 			Name:    "test",
 			Aliases: []string{"t"},
 			Usage:   "Command to read and parse JSON information about Storj network and upload sample data",
-			//\n arguments- 1. fileName [optional] = provide full file name (with complete path), storing Storj configuration information
-			//if this fileName is not given, then data is read from ./config/storj_config.json
-			//example = ./storj-zenko t ./config/storj_config.json\n\n\n",
+			// flags- --storj-config FILE (default ./config/storj_config.json)
+			// example = ./storj-zenko t --storj-config ./config/storj_config.json
+			ArgsUsage: connectionArgsUsage(false, true),
+			Flags:     connectionFlags(false, true),
 			Action: func(cliContext *cli.Context) error {
 
-				// Default Storj configuration file name.
-				var fullFileName = storjConfigFile
-				var foundFirstFileName = false
-				var foundSecondFileName = false
-				var keyValue string
-				var restrict string
-
-				gbDEBUG = false
-
-				// process arguments
-				if len(cliContext.Args().Slice()) > 0 {
-					for i := 0; i < len(cliContext.Args().Slice()); i++ {
-
-						// Incase, debug is provided as argument.
-						if cliContext.Args().Slice()[i] == "debug" {
-							setDebug(true)
-						} else {
-							if !foundFirstFileName {
-								fullFileName = cliContext.Args().Slice()[i]
-								foundFirstFileName = true
-							} else {
-								if !foundSecondFileName {
-
-									keyValue = cliContext.Args().Slice()[i]
-									foundSecondFileName = true
-								} else {
-									restrict = cliContext.Args().Slice()[i]
-								}
-							}
-						}
-					}
+				conn, err := parseConnection(cliContext, false, true)
+				if err != nil {
+					return err
 				}
 				//Sample data to be uploaded with sample data name
 				fileName := "testdata"
@@ -163,18 +452,22 @@ func setCommands() {
 				var fileNamesDEBUG []string
 
 				// Connect to storj network.
-				ctx, uplink, project, bucket, storjConfig, _, errr := storj.ConnectStorjReadUploadData(fullFileName, keyValue, restrict)
+				ctx, uplink, project, storjBucket, storjConfig, _, errr := storj.ConnectStorjReadUploadData(conn.storjConfig, conn.deriveScope, conn.restrict)
+				bucket := storj.NewBucket(storjBucket)
+				if errr != nil {
+					return errr
+				}
 
 				// Upload sample data on storj network.
-				fileNamesDEBUG = storj.ConnectUpload(ctx, bucket, data, fileName, fileNamesDEBUG, storjConfig, errr)
+				fileNamesDEBUG, errr = storj.ConnectUpload(ctx, bucket, data, fileName, fileNamesDEBUG, storjConfig)
+
+				// Close storj project.
+				storj.CloseProject(uplink, project, storjBucket)
 
 				if errr != nil {
 					return errr
 				}
 
-				// Close storj project.
-				storj.CloseProject(uplink, project, bucket)
-
 				fmt.Println("\nUpload \"testdata\" on Storj: Successful!")
 				return errr
 			},
@@ -183,69 +476,278 @@ func setCommands() {
 			Name:    "store",
 			Aliases: []string{"s"},
 			Usage:   "Command to connect and transfer file(s)/folder(s) from a desired Zenko Orbit account to given Storj Bucket.",
-			//\n    arguments-\n      1. fileName [optional] = provide full file name (with complete path),
-			// storing zenko properties in JSON format\n   if this fileName is not given,
-			// then data is read from ./config/zenko_property.json\n
-			// 2. fileName [optional] = provide full file name (with complete path), storing Storj
-			// configuration in JSON format\n     if this fileName is not given, then
-			// data is read from ./config/storj_config.json\n
-			// example = ./storj-zenko s ./config/zenko_property.json ./config/storj_config.json fileName/DirectoryName\n"
+			// flags- --zenko-config FILE (default ./config/zenko_property.json), --storj-config FILE (default ./config/storj_config.json)
+			// example = ./storj-zenko s --zenko-config ./config/zenko_property.json --storj-config ./config/storj_config.json
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:  "chunking",
+					Value: chunker.Fixed,
+					Usage: "chunking `STRATEGY`: fixed (split objects into --chunk-size chunks), content (content-defined chunks, deduplicated across snapshots) or none (one Storj object per Zenko object)",
+				},
+				&cli.StringFlag{
+					Name:  "chunk-size",
+					Usage: "`SIZE` of fixed chunks (default 64MiB) or average size of content-defined chunks (default 4MiB), e.g. 32KiB, 64MiB or 1GiB",
+				},
+				&cli.BoolFlag{
+					Name:  "stream",
+					Usage: "same as --chunking none",
+				},
+				&cli.IntFlag{
+					Name:  "workers",
+					Value: 1,
+					Usage: "number of objects transferred at the same time",
+				},
+				&cli.StringFlag{
+					Name:  "resume",
+					Usage: "continue the interrupted run `ID` (<time> or <zenkoBucket>_<time>) from its checkpoint, skipping completed work",
+				},
+				&cli.BoolFlag{
+					Name:  "incremental",
+					Usage: "only transfer objects that are new or changed since the previous run, referencing unchanged objects from the earlier snapshot",
+				},
+				&cli.BoolFlag{
+					Name:  "continue-on-error",
+					Usage: "skip objects that fail after all retries and report them at the end, instead of stopping the run",
+				},
+				&cli.StringFlag{
+					Name:  "failure-report",
+					Usage: "write the objects that failed to the JSON `FILE`",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "list the objects that would be copied, their Storj paths and chunking, without uploading anything or creating the Storj bucket",
+				},
+				&cli.BoolFlag{
+					Name:  "no-tags",
+					Usage: "do not back up object tags, saving one request per object",
+				},
+				&cli.BoolFlag{
+					Name:  "versions",
+					Usage: "copy every version and delete marker of versioned buckets, each version under " + storj.VersionFolder + "/<key>/<versionId> in the snapshot",
+				},
+				&cli.StringFlag{
+					Name:  "source-dir",
+					Usage: "back up the files below the local `DIR` instead of Zenko, keeping their relative paths, modification times and permissions",
+				},
+				&cli.StringFlag{
+					Name:  "source-name",
+					Usage: "`NAME` the files of --source-dir are recorded under in place of a Zenko bucket name (default: the name of the directory)",
+				},
+			}, append(append(filterFlags(), retryFlags()...), connectionFlags(true, true)...)...),
+			ArgsUsage: connectionArgsUsage(true, true),
 			Action: func(cliContext *cli.Context) error {
 
-				// Default configuration file names.
-				var fullFileNameStorj = storjConfigFile
-				var fullFileNameZenko = zenkoConfigFile
-				var keyValue string
-				var restrict string
-				var fileNamesDEBUG []string
+				conn, err := parseConnection(cliContext, true, true)
+				if err != nil {
+					return err
+				}
 
-				// process arguments - Reading file names from the command line.
-				var foundFirstFileName = false
-				var foundSecondFileName = false
-				var foundThirdFileName = false
-
-				if len(cliContext.Args().Slice()) > 0 {
-					for i := 0; i < len(cliContext.Args().Slice()); i++ {
-						// Incase debug is provided as argument.
-						if cliContext.Args().Slice()[i] == "debug" {
-							setDebug(true)
-						} else {
-							if !foundFirstFileName {
-								fullFileNameZenko = cliContext.Args().Slice()[i]
-								foundFirstFileName = true
-							} else {
-								if !foundSecondFileName {
-									fullFileNameStorj = cliContext.Args().Slice()[i]
-									foundSecondFileName = true
-								} else {
-									if !foundThirdFileName {
-										keyValue = cliContext.Args().Slice()[i]
-										foundThirdFileName = true
-									} else {
-										restrict = cliContext.Args().Slice()[i]
-									}
-								}
-							}
-						}
+				// Read the chunking strategy.
+				chunking := chunker.Config{Strategy: cliContext.String("chunking")}
+				if cliContext.Bool("stream") {
+					chunking.Strategy = chunker.None
+				}
+				switch chunking.Strategy {
+				case chunker.Fixed:
+					chunking.Size = chunker.DefaultChunkSize
+				case chunker.Content:
+					chunking.Size = chunker.DefaultAverageChunkSize
+				}
+				if chunking.Strategy != chunker.None && cliContext.IsSet("chunk-size") {
+					chunkSize, err := chunker.ParseSize(cliContext.String("chunk-size"))
+					if err != nil {
+						return err
 					}
+					chunking.Size = chunkSize
+				}
+				if err := chunking.Validate(); err != nil {
+					return err
+				}
+				policy, err := retryPolicy(cliContext)
+				if err != nil {
+					return err
 				}
 
-				// Establish connection with Zenko and get io.Reader implementor.
-				zenkoReader, err := zenko.ConnectToZenko(fullFileNameZenko)
+				// Files of a local directory are backed up without connecting to Zenko.
+				zenkoReader := &zenko.ZenkoReader{}
+				sourceDir := cliContext.String("source-dir")
+				if sourceDir == "" {
+					// Establish connection with Zenko and get io.Reader implementor.
+					zenkoReader, err = zenko.ConnectToZenko(conn.zenkoConfig)
+					if err != nil {
+						log.Fatalf("Failed to establish connection with Zenko: %s\n", err)
+					}
+
+					// Inform about successful connection.
+					fmt.Println("Successfully connected to Zenko!")
+				}
+
+				// Select buckets and keys; a resumed run keeps the rules it was started with.
+				filterRules, err := filterConfig(cliContext, zenkoReader.Config.Filter)
 				if err != nil {
-					log.Fatalf("Failed to establish connection with Zenko: %s\n", err)
+					return err
 				}
 
-				// Create a done channel to control 'ListObjects' go routine.
-				doneCh := make(chan struct{})
+				// Connect to storj network and returns context, uplink, project, bucket and storj configuration.
+				dryRun := cliContext.Bool("dry-run")
+				ctx, uplink, project, storjBucket, storjConfig, scope, errr := storj.ConnectStorj(conn.storjConfig, conn.deriveScope, conn.restrict, !dryRun)
+				bucket := storj.NewBucket(storjBucket)
+				if errr != nil && dryRun && cliContext.String("resume") == "" {
+					// A dry run never creates the bucket; without it there is no earlier run to compare against.
+					fmt.Println("Could not open Storj bucket, it would be created:", errr)
+					storjConfig, errr = storj.LoadStorjConfiguration(conn.storjConfig)
+				}
+				if errr != nil {
+					log.Fatal(errr)
+				}
+				defer storj.CloseProject(uplink, project, storjBucket)
 
-				// Indicate to our routine to exit cleanly upon return.
-				defer close(doneCh)
+				config, manifest, err := backup.Prepare(ctx, backup.StoreOptions{
+					Bucket:          bucket,
+					Storj:           storjConfig,
+					Zenko:           zenkoReader,
+					Dir:             sourceDir,
+					Name:            cliContext.String("source-name"),
+					Resume:          cliContext.String("resume"),
+					Incremental:     cliContext.Bool("incremental"),
+					Versions:        cliContext.Bool("versions"),
+					NoTags:          cliContext.Bool("no-tags"),
+					Filter:          filterRules,
+					Chunking:        chunking,
+					Workers:         cliContext.Int("workers"),
+					Retry:           policy,
+					ContinueOnError: cliContext.Bool("continue-on-error"),
+					Version:         app.Version,
+				})
+				if err != nil {
+					return fmt.Errorf("store: %v", err)
+				}
+
+				// Only show what would be copied in a dry run.
+				if dryRun {
+					plan, err := backup.DryRun(ctx, config, manifest)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("\nDry run of run %s with %s chunking, nothing was uploaded:\n", manifest.RunID, config.Chunking.Strategy)
+					if manifest.Versions {
+						fmt.Println("  every object version is counted as an object")
+					}
+					fmt.Printf("  %d objects (%d bytes) would be copied\n", plan.Objects, plan.Bytes)
+					if plan.Referenced > 0 {
+						fmt.Printf("  %d unchanged objects (%d bytes) would be referenced from run %s\n", plan.Referenced, plan.ReferencedBytes, manifest.Base)
+					}
+					if plan.Completed > 0 {
+						fmt.Printf("  %d objects were already copied before the run was interrupted\n", plan.Completed)
+					}
+					return nil
+				}
 
-				isRecursive := true
+				// Copy all objects with a pool of workers and record them in the manifest.
+				err = backup.Store(ctx, config, manifest)
+				if err != nil {
+					resume := "--resume " + manifest.RunID
+					if manifest.Dir != "" {
+						resume += " --source-dir " + manifest.Dir
+					}
+					fmt.Println("\nThe run was interrupted. Continue it with: storj-zenko store", resume)
+					log.Fatal("Store Error:", err)
+				}
+				fmt.Println("\nManifest Path: ", storj.ManifestPath(storjConfig.UploadPath, manifest.RunID))
+				if retries := policy.Retries(); retries > 0 {
+					fmt.Println("Retried Calls: ", retries)
+				}
+				// Skipped objects make the command fail once everything else is done.
+				var failed error
+				if len(manifest.Failures) > 0 {
+					printFailures(manifest.Failures)
+					failed = fmt.Errorf("store: %d objects could not be copied", len(manifest.Failures))
+				}
+				if reportFile := cliContext.String("failure-report"); reportFile != "" {
+					if reportErr := writeFailureReport(reportFile, manifest.Failures); reportErr != nil {
+						fmt.Println("Could not write failure report:", reportErr)
+					}
+				}
+
+				// Download the uploaded data again to verify it in debug mode.
+				if gbDEBUG {
+					for _, zenkoBucket := range manifest.Buckets {
+						objects, err := storj.SnapshotObjects(ctx, bucket, storjConfig.UploadPath, storj.Snapshot{Bucket: zenkoBucket, Time: manifest.Start, Manifest: manifest})
+						if err != nil {
+							log.Fatal(err)
+						}
+						err = storj.RestoreToDir(ctx, bucket, objects, filepath.Join("debug", zenkoBucket+"_"+manifest.RunID), storj.ExistingOverwrite, policy)
+						if err != nil {
+							log.Fatal(err)
+						}
+					}
+				}
+
+				fmt.Println(" ")
+				printScope(conn, scope)
+
+				return failed
+			},
+		},
+		{
+			Name:    "sync",
+			Aliases: []string{"m"},
+			Usage:   "Command to keep a mirror of every Zenko Orbit Bucket in the given Storj Bucket, uploading new and changed file(s) and deleting file(s) no longer in Zenko.",
+			// flags- --zenko-config FILE (default ./config/zenko_property.json), --storj-config FILE (default ./config/storj_config.json)
+			// example = ./storj-zenko m --zenko-config ./config/zenko_property.json --storj-config ./config/storj_config.json
+			Flags: append([]cli.Flag{
+				&cli.IntFlag{
+					Name:  "workers",
+					Value: 1,
+					Usage: "number of objects transferred at the same time",
+				},
+				&cli.IntFlag{
+					Name:  "max-delete",
+					Usage: "refuse to sync a bucket when more than `N` mirrored objects would be deleted (default: no limit)",
+				},
+				&cli.Float64Flag{
+					Name:  "max-delete-percent",
+					Value: backup.DefaultDeletePercent,
+					Usage: "refuse to sync a bucket when more than `PERCENT` of its mirrored objects would be deleted, 100 to allow any deletion",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "list the objects that would be uploaded and deleted, without changing the mirror",
+				},
+				&cli.BoolFlag{
+					Name:  "no-tags",
+					Usage: "do not back up object tags, saving one request per object",
+				},
+			}, append(append(filterFlags(), retryFlags()...), connectionFlags(true, true)...)...),
+			ArgsUsage: connectionArgsUsage(true, true),
+			Action: func(cliContext *cli.Context) error {
+
+				conn, err := parseConnection(cliContext, true, true)
+				if err != nil {
+					return err
+				}
+
+				policy, err := retryPolicy(cliContext)
+				if err != nil {
+					return err
+				}
+				limit := backup.DeleteLimit{
+					Max:     cliContext.Int("max-delete"),
+					Percent: cliContext.Float64("max-delete-percent"),
+				}
+
+				// Establish connection with Zenko and get io.Reader implementor.
+				zenkoReader, err := zenko.ConnectToZenko(conn.zenkoConfig)
+				if err != nil {
+					log.Fatalf("Failed to establish connection with Zenko: %s\n", err)
+				}
 
 				// List of all buckets from Zenko orbit.
-				buckets, err := zenkoReader.Client.ListBuckets()
+				var buckets []minio.BucketInfo
+				err = policy.Do(context.Background(), "list buckets", func() (err error) {
+					buckets, err = zenkoReader.Client.ListBuckets()
+					return err
+				})
 				if err != nil {
 					log.Fatal("List Bucket Error:", err)
 				}
@@ -253,94 +755,499 @@ func setCommands() {
 				// Inform about successful connection.
 				fmt.Println("Successfully connected to Zenko!")
 
+				filterRules, err := filterConfig(cliContext, zenkoReader.Config.Filter)
+				if err != nil {
+					return err
+				}
+				keys, err := filter.New(filterRules)
+				if err != nil {
+					return err
+				}
+
 				// Connect to storj network and returns context, uplink, project, bucket and storj configuration.
-				ctx, uplink, project, bucket, storjConfig, scope, errr := storj.ConnectStorjReadUploadData(fullFileNameStorj, keyValue, restrict)
+				dryRun := cliContext.Bool("dry-run")
+				ctx, uplink, project, storjBucket, storjConfig, scope, errr := storj.ConnectStorj(conn.storjConfig, conn.deriveScope, conn.restrict, !dryRun)
+				bucket := storj.NewBucket(storjBucket)
+				if errr != nil && dryRun {
+					// A dry run never creates the bucket; without it every mirror is empty.
+					fmt.Println("Could not open Storj bucket, it would be created:", errr)
+					storjConfig, errr = storj.LoadStorjConfiguration(conn.storjConfig)
+				}
 				if errr != nil {
-					log.Fatal(err)
+					log.Fatal(errr)
 				}
+				defer storj.CloseProject(uplink, project, storjBucket)
 
-				storePath := make([]string, 0)
-				storeExt := make([]string, 0)
-				t := time.Now()
-				timeNow := t.Format("2006-01-02_15_04_05")
+				source := &zenko.Source{Client: zenkoReader.Client, Tagger: zenkoReader.Tagger, Retry: policy}
+				if cliContext.Bool("no-tags") {
+					source.Tagger = nil
+				}
+				config := backup.Config{
+					Source:  source,
+					Bucket:  bucket,
+					Storj:   storjConfig,
+					Workers: cliContext.Int("workers"),
+					Filter:  keys,
+					Retry:   policy,
+				}
+
+				// Plan every bucket first, so that a refused deletion leaves all mirrors untouched.
+				var plans []backup.SyncPlan
+				var states []*storj.MirrorState
 				for _, zenkoBucket := range buckets {
-					// ListObjects lists all objects from the specified bucket.
-					objectCh := zenkoReader.Client.ListObjects(zenkoBucket.Name, "", isRecursive, doneCh)
-					for object := range objectCh {
-						if object.Err != nil {
-							log.Fatal("Object Information Error", object.Err)
+					if !keys.Bucket(zenkoBucket.Name) {
+						continue
+					}
+					state := &storj.MirrorState{Bucket: zenkoBucket.Name}
+					err = policy.Do(ctx, "load mirror state", func() (err error) {
+						if bucket == nil {
+							return nil
+						}
+						state, err = storj.LoadMirrorState(ctx, bucket, storjConfig.UploadPath, zenkoBucket.Name)
+						return err
+					})
+					if err != nil {
+						log.Fatal("Load Mirror State Error:", err)
+					}
+					plan, err := backup.PlanSync(ctx, config, zenkoBucket.Name, state)
+					if err != nil {
+						return err
+					}
+					if err := limit.Check(plan); err != nil {
+						return fmt.Errorf("sync: %v; raise --max-delete or --max-delete-percent if this is intended", err)
+					}
+					plans = append(plans, plan)
+					states = append(states, state)
+				}
+
+				for i, plan := range plans {
+					fmt.Printf("\nZenko Bucket: %s -> %s\n", plan.Bucket, storj.MirrorPrefix(storjConfig.UploadPath, plan.Bucket))
+					fmt.Printf("  %d objects (%d bytes) to upload, %d to delete, %d unchanged\n", len(plan.Upload), plan.UploadBytes, len(plan.Delete), plan.Unchanged())
+					if dryRun {
+						for _, object := range plan.Upload {
+							fmt.Printf("  upload %s (%d bytes)\n", object.Key, object.Size)
+						}
+						for _, key := range plan.Delete {
+							fmt.Printf("  delete %s\n", key)
 						}
+						continue
+					}
 
-						fmt.Println("\nReading content from the file :", object.Key)
-						var temp int64 = 0
-						var fileExtension string
-						var zenkoPath string
-						i := 0
-						for temp < object.Size {
-							// GetObject function returns seekable, readable object.
-							objectReader, err := zenkoReader.Client.GetObject(zenkoBucket.Name, object.Key, minio.GetObjectOptions{})
+					err := backup.Sync(ctx, config, plan, states[i])
+					// Save what was mirrored, also when the sync failed.
+					saveErr := policy.Do(ctx, "save mirror state", func() error {
+						return storj.SaveMirrorState(ctx, bucket, storjConfig.UploadPath, states[i])
+					})
+					if err != nil {
+						return fmt.Errorf("sync: %v", err)
+					}
+					if saveErr != nil {
+						log.Fatal("Save Mirror State Error:", saveErr)
+					}
+				}
 
-							if err != nil {
-								log.Fatal(err)
-							}
+				if dryRun {
+					fmt.Println("\nDry run, the mirrors were not changed.")
+					return nil
+				}
+				if retries := policy.Retries(); retries > 0 {
+					fmt.Println("\nRetried Calls: ", retries)
+				}
+				fmt.Println("\nSyncing Zenko to Storj: Complete!")
+				printScope(conn, scope)
+				return nil
+			},
+		},
+		{
+			Name:    "migrate",
+			Aliases: []string{"g"},
+			Usage:   "Command to copy plain object(s) from the given Storj Bucket into a Zenko Orbit Bucket under the same keys, with their metadata.",
+			// flags- --zenko-config FILE (default ./config/zenko_property.json), --storj-config FILE (default ./config/storj_config.json)
+			// example = ./storj-zenko g --bucket mybucket --zenko-config ./config/zenko_property.json --storj-config ./config/storj_config.json
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:  "prefix",
+					Usage: "only copy the Storj objects below `PREFIX`, which is removed from their keys (default: the whole bucket)",
+				},
+				&cli.StringFlag{
+					Name:  "bucket",
+					Usage: "Zenko `BUCKET` to copy into (default: the name of the Storj bucket)",
+				},
+				&cli.BoolFlag{
+					Name:  "skip-existing",
+					Usage: "skip objects that already exist in the Zenko bucket with the same size, to continue an interrupted migration",
+				},
+				&cli.IntFlag{
+					Name:  "workers",
+					Value: 1,
+					Usage: "number of objects transferred at the same time",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "list the objects that would be copied, without writing anything or creating the Zenko bucket",
+				},
+			}, append(retryFlags(), connectionFlags(true, true)...)...),
+			ArgsUsage: connectionArgsUsage(true, true),
+			Action: func(cliContext *cli.Context) error {
+
+				conn, err := parseConnection(cliContext, true, true)
+				if err != nil {
+					return err
+				}
+
+				policy, err := retryPolicy(cliContext)
+				if err != nil {
+					return err
+				}
+
+				// Connect to storj network; the bucket is only read.
+				ctx, uplink, project, storjBucket, storjConfig, _, errr := storj.ConnectStorj(conn.storjConfig, conn.deriveScope, conn.restrict, false)
+				bucket := storj.NewBucket(storjBucket)
+				if errr != nil {
+					log.Fatal(errr)
+				}
+				defer storj.CloseProject(uplink, project, storjBucket)
+
+				// Establish connection with Zenko and get io.Reader implementor.
+				zenkoReader, err := zenko.ConnectToZenko(conn.zenkoConfig)
+				if err != nil {
+					log.Fatalf("Failed to establish connection with Zenko: %s\n", err)
+				}
+
+				// Inform about successful connection.
+				fmt.Println("Successfully connected to Zenko!")
+
+				targetBucket := cliContext.String("bucket")
+				if targetBucket == "" {
+					targetBucket = storjConfig.Bucket
+				}
+				config := backup.MigrateConfig{
+					Bucket:       bucket,
+					Prefix:       cliContext.String("prefix"),
+					Target:       &zenko.Destination{Client: zenkoReader.Client, Tagger: zenkoReader.Tagger, Bucket: targetBucket},
+					SkipExisting: cliContext.Bool("skip-existing"),
+					DryRun:       cliContext.Bool("dry-run"),
+					Workers:      cliContext.Int("workers"),
+					Retry:        policy,
+				}
+
+				// Make sure the target bucket exists.
+				var exists bool
+				err = policy.Do(ctx, "check bucket "+targetBucket, func() (err error) {
+					exists, err = zenkoReader.Client.BucketExists(targetBucket)
+					return err
+				})
+				if err != nil {
+					log.Fatal("Bucket Exists Error:", err)
+				}
+				if !exists && config.DryRun {
+					fmt.Println("Zenko Bucket would be created: ", targetBucket)
+					// Nothing can exist in a bucket that does not.
+					config.SkipExisting = false
+				} else if !exists {
+					fmt.Println("Creating Zenko Bucket: ", targetBucket)
+					err = policy.Do(ctx, "create bucket "+targetBucket, func() error {
+						return zenkoReader.Client.MakeBucket(targetBucket, zenkoReader.Config.Region)
+					})
+					if err != nil {
+						log.Fatal("Make Bucket Error:", err)
+					}
+				}
+
+				fmt.Printf("\nMigrating Storj Bucket %s/%s to Zenko Bucket %s...\n", storjConfig.Bucket, config.Prefix, targetBucket)
+				result, err := backup.Migrate(ctx, config)
+				if err != nil {
+					return fmt.Errorf("migrate: %v", err)
+				}
+
+				if config.DryRun {
+					fmt.Printf("\nDry run, nothing was copied: %d objects (%d bytes) would be copied\n", result.Objects, result.Bytes)
+				} else {
+					fmt.Printf("\n%d objects (%d bytes) copied\n", result.Objects, result.Bytes)
+				}
+				if result.Skipped > 0 {
+					fmt.Printf("%d objects already existed and were skipped\n", result.Skipped)
+				}
+				if retries := policy.Retries(); retries > 0 {
+					fmt.Println("Retried Calls: ", retries)
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "snapshots",
+			Aliases: []string{"l"},
+			Usage:   "Command to list the backup snapshots stored in the given Storj Bucket, grouped by Zenko Orbit Bucket and time, with object counts and sizes.",
+			// flags- --storj-config FILE (default ./config/storj_config.json)
+			// example = ./storj-zenko l --storj-config ./config/storj_config.json
+			ArgsUsage: connectionArgsUsage(false, true),
+			Flags:     connectionFlags(false, true),
+			Action: func(cliContext *cli.Context) error {
+
+				conn, err := parseConnection(cliContext, false, true)
+				if err != nil {
+					return err
+				}
+
+				// Connect to storj network.
+				ctx, uplink, project, storjBucket, storjConfig, _, errr := storj.ConnectStorjReadUploadData(conn.storjConfig, conn.deriveScope, conn.restrict)
+				bucket := storj.NewBucket(storjBucket)
+				if errr != nil {
+					return errr
+				}
+				defer storj.CloseProject(uplink, project, storjBucket)
+
+				snapshots, err := storj.ListSnapshots(ctx, bucket, storjConfig.UploadPath)
+				if err != nil {
+					return err
+				}
+
+				// Group the snapshots by Zenko bucket, keeping them ordered by time.
+				var zenkoBuckets []string
+				byBucket := make(map[string][]storj.Snapshot)
+				for _, snapshot := range snapshots {
+					if _, ok := byBucket[snapshot.Bucket]; !ok {
+						zenkoBuckets = append(zenkoBuckets, snapshot.Bucket)
+					}
+					byBucket[snapshot.Bucket] = append(byBucket[snapshot.Bucket], snapshot)
+				}
+				sort.Strings(zenkoBuckets)
+
+				for _, zenkoBucket := range zenkoBuckets {
+					fmt.Printf("\nZenko Bucket: %s\n", zenkoBucket)
+					fmt.Printf("  %-45s %-25s %10s %15s\n", "SNAPSHOT ID", "TIME", "OBJECTS", "SIZE (BYTES)")
+					for _, snapshot := range byBucket[zenkoBucket] {
+						count, size, err := storj.SnapshotSummary(ctx, bucket, snapshot)
+						if err != nil {
+							return err
+						}
+						fmt.Printf("  %-45s %-25s %10d %15d\n", snapshot.ID, snapshot.Time.Format(time.RFC3339), count, size)
+						if snapshot.Filter != nil {
+							fmt.Printf("    only %s\n", snapshot.Filter)
+						}
+						if snapshot.Versions {
+							fmt.Println("    with every object version, the counts are of current versions")
+						}
+						if snapshot.Dir != "" {
+							fmt.Printf("    files of directory %s\n", snapshot.Dir)
+						}
+					}
+				}
+
+				fmt.Printf("\n%d snapshot(s) found below %q\n", len(snapshots), storjConfig.UploadPath)
+				return nil
+			},
+		},
+		{
+			Name:    "restore",
+			Aliases: []string{"r"},
+			Usage:   "Command to download backed up file(s) from the given Storj Bucket, reassemble their chunks and upload them back to a Zenko Orbit Bucket.",
+			// flags- --zenko-config FILE (default ./config/zenko_property.json), --storj-config FILE (default ./config/storj_config.json)
+			// example = ./storj-zenko r --snapshot bucket_2020-03-23_10_00_00 --zenko-config ./config/zenko_property.json --storj-config ./config/storj_config.json
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:    "snapshot",
+					Aliases: []string{"prefix"},
+					Usage:   "snapshot `ID` to restore: <zenkoBucket>_<time> for one bucket or <time> for every bucket of a run",
+				},
+				&cli.StringFlag{
+					Name:  "bucket",
+					Usage: "Zenko `BUCKET` to restore into (default: the bucket the backup was taken from)",
+				},
+				&cli.StringFlag{
+					Name:  "to-dir",
+					Usage: "restore into the local directory `DIR` (as DIR/<bucket>/<key>) instead of Zenko",
+				},
+				&cli.StringFlag{
+					Name:  "if-exists",
+					Value: storj.ExistingFail,
+					Usage: "what to do with files that already exist in --to-dir: fail, skip or overwrite",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "list the objects that would be restored and where to, without writing anything or creating buckets",
+				},
+				&cli.BoolFlag{
+					Name:  "all-versions",
+					Usage: "restore every version of snapshots taken with store --versions, oldest first, including delete markers; with --to-dir older versions are written to " + storj.VersionFolder + "/<key>/<versionId>",
+				},
+			}, append(retryFlags(), connectionFlags(true, true)...)...),
+			ArgsUsage: connectionArgsUsage(true, true),
+			Action: func(cliContext *cli.Context) error {
+
+				conn, err := parseConnection(cliContext, true, true)
+				if err != nil {
+					return err
+				}
+
+				snapshotID := cliContext.String("snapshot")
+				if snapshotID == "" {
+					return fmt.Errorf("restore: --snapshot is required, use the snapshots command to list them")
+				}
+				policy, err := retryPolicy(cliContext)
+				if err != nil {
+					return err
+				}
+
+				// Connect to storj network and returns context, uplink, project, bucket and storj configuration.
+				dryRun := cliContext.Bool("dry-run")
+				ctx, uplink, project, storjBucket, storjConfig, _, errr := storj.ConnectStorj(conn.storjConfig, conn.deriveScope, conn.restrict, !dryRun)
+				bucket := storj.NewBucket(storjBucket)
+				if errr != nil {
+					log.Fatal(errr)
+				}
+				defer storj.CloseProject(uplink, project, storjBucket)
 
-							section := io.NewSectionReader(objectReader, temp,32*1024)
-							bytes, err := ioutil.ReadAll(section)
+				var snapshots []storj.Snapshot
+				err = policy.Do(ctx, "list snapshots", func() (err error) {
+					snapshots, err = storj.ListSnapshots(ctx, bucket, storjConfig.UploadPath)
+					return err
+				})
+				if err != nil {
+					log.Fatal("List Snapshots Error:", err)
+				}
+				snapshots = storj.SelectSnapshots(snapshots, snapshotID)
+				if len(snapshots) == 0 {
+					return fmt.Errorf("restore: snapshot %q not found below %q", snapshotID, storjConfig.UploadPath)
+				}
+				if cliContext.String("bucket") != "" && len(snapshots) > 1 {
+					return fmt.Errorf("restore: --bucket needs a single snapshot, %q selects %d", snapshotID, len(snapshots))
+				}
+
+				var zenkoReader *zenko.ZenkoReader
+				toDir := cliContext.String("to-dir")
+				if toDir == "" {
+					// Establish connection with Zenko and get io.Reader implementor.
+					zenkoReader, err = zenko.ConnectToZenko(conn.zenkoConfig)
+					if err != nil {
+						log.Fatalf("Failed to establish connection with Zenko: %s\n", err)
+					}
+
+					// Inform about successful connection.
+					fmt.Println("Successfully connected to Zenko!")
+				}
+
+				var plannedObjects int
+				var plannedBytes int64
+				for _, snapshot := range snapshots {
+					targetBucket := cliContext.String("bucket")
+					if targetBucket == "" {
+						targetBucket = snapshot.Bucket
+					}
+
+					fmt.Printf("\nRestoring snapshot %s taken at %s...\n", snapshot.ID, snapshot.Time.Format(time.RFC3339))
+					if snapshot.Filter != nil {
+						fmt.Printf("The snapshot only covers %s\n", snapshot.Filter)
+					}
+					allVersions := cliContext.Bool("all-versions")
+					if allVersions && !snapshot.Versions {
+						fmt.Println("The snapshot only holds the current versions of the objects.")
+					}
+					var objects []storj.ChunkedObject
+					err := policy.Do(ctx, "list "+snapshot.ID, func() (err error) {
+						if allVersions {
+							objects, err = storj.SnapshotVersions(ctx, bucket, storjConfig.UploadPath, snapshot)
+							return err
+						}
+						objects, err = storj.SnapshotObjects(ctx, bucket, storjConfig.UploadPath, snapshot)
+						return err
+					})
+					if err != nil {
+						log.Fatal("List Storj Objects Error:", err)
+					}
+
+					// Only show what would be restored in a dry run.
+					if dryRun {
+						if toDir == "" {
+							var exists bool
+							err := policy.Do(ctx, "check bucket "+targetBucket, func() (err error) {
+								exists, err = zenkoReader.Client.BucketExists(targetBucket)
+								return err
+							})
 							if err != nil {
-								log.Fatal(err)
+								log.Fatal("Bucket Exists Error:", err)
 							}
-
-							var path string
-							if filepath.Dir(object.Key) == "." {
-								path = ""
-							} else {
-								path = filepath.Dir(object.Key) + "/"
+							if !exists {
+								fmt.Println("Zenko Bucket would be created: ", targetBucket)
 							}
-							file := filepath.Base(object.Key)
-							split := strings.Split(file, ".")
-							fileExtension = split[len(split)-1]
-							if len(split) > 2 {
-								fileExtension = split[len(split)-2] + "." + split[len(split)-1]
+						}
+						for _, object := range objects {
+							name := object.Key
+							if object.VersionID != "" {
+								name += " version " + object.VersionID
 							}
-							lastFileName := split[0]
-							for i := 1; i < len(split)-2; i++ {
-								lastFileName = lastFileName + split[i] + "."
-								if i == len(split)-3 {
-									lastFileName = lastFileName + split[i]
+							if object.DeleteMarker {
+								if toDir == "" {
+									fmt.Printf("  %s: delete marker -> would delete %s/%s\n", name, targetBucket, object.Key)
 								}
-
+								continue
 							}
-							zenkoPath = zenkoBucket.Name + "_" + timeNow + "/" + path + lastFileName
-							zenkoFilePath := zenkoPath + "/" + strconv.Itoa(i) + "." + fileExtension
-							i++
-							// Upload Zenko object on storj Network with file name.
-							storj.ConnectUpload(ctx, bucket, bytes, zenkoFilePath, fileNamesDEBUG, storjConfig, errr)
-							if errr != nil {
-								log.Fatal(errr)
+							target := targetBucket + "/" + object.Key
+							if toDir != "" {
+								target, err = storj.RestorePath(filepath.Join(toDir, targetBucket), object.FileKey())
+								if err != nil {
+									return fmt.Errorf("restore: %v", err)
+								}
+								if _, err := os.Stat(target); err == nil {
+									target += " (exists, --if-exists " + cliContext.String("if-exists") + ")"
+								}
 							}
-							temp = temp + int64(len(bytes))
+							fmt.Printf("  %s (%d bytes, %d chunks) -> %s\n", name, object.Size, len(object.Chunks), target)
+							plannedObjects++
+							plannedBytes += object.Size
 						}
-						storeExt = append(storeExt, fileExtension)
-						storePath = append(storePath, zenkoPath)
+						continue
 					}
-				}
-				// Debug the StorJ data.
-				storj.Debug(ctx, bucket, storjConfig.UploadPath, storePath, storeExt)
 
-				// Close the StorJ project.
-				storj.CloseProject(uplink, project, bucket)
-				fmt.Println(" ")
-				if keyValue == "key" {
-					if restrict == "restrict" {
-						fmt.Println("Restricted Serialized Scope Key: ", scope)
-						fmt.Println(" ")
-					} else {
-						fmt.Println("Serialized Scope Key: ", scope)
-						fmt.Println(" ")
+					// Restore into the local file system.
+					if toDir != "" {
+						err = storj.RestoreToDir(ctx, bucket, objects, filepath.Join(toDir, targetBucket), cliContext.String("if-exists"), policy)
+						if err != nil {
+							return fmt.Errorf("restore: %v", err)
+						}
+						continue
+					}
+
+					// Make sure the target bucket exists.
+					var exists bool
+					err = policy.Do(ctx, "check bucket "+targetBucket, func() (err error) {
+						exists, err = zenkoReader.Client.BucketExists(targetBucket)
+						return err
+					})
+					if err != nil {
+						log.Fatal("Bucket Exists Error:", err)
+					}
+					if !exists {
+						fmt.Println("Creating Zenko Bucket: ", targetBucket)
+						err = policy.Do(ctx, "create bucket "+targetBucket, func() error {
+							return zenkoReader.Client.MakeBucket(targetBucket, zenkoReader.Config.Region)
+						})
+						if err != nil {
+							log.Fatal("Make Bucket Error:", err)
+						}
+					}
+
+					// Write the objects, and delete the keys of delete markers, through the Zenko destination.
+					fmt.Printf("\nRestoring %d objects to Zenko Bucket %s...\n", len(objects), targetBucket)
+					target := &zenko.Destination{Client: zenkoReader.Client, Tagger: zenkoReader.Tagger, Bucket: targetBucket}
+					if err := storj.Restore(ctx, bucket, objects, target, policy); err != nil {
+						log.Fatal("Restore Error:", err)
 					}
 				}
 
-				return err
+				if dryRun {
+					fmt.Printf("\nDry run, nothing was restored: %d objects (%d bytes) would be restored\n", plannedObjects, plannedBytes)
+					return nil
+				}
+				if retries := policy.Retries(); retries > 0 {
+					fmt.Println("\nRetried Calls: ", retries)
+				}
+				if toDir != "" {
+					fmt.Println("\nRestoring backup from Storj to", toDir, ": Complete!")
+					return nil
+				}
+				fmt.Println("\nRestoring backup from Storj to Zenko: Complete!")
+				return nil
 			},
 		},
 	}
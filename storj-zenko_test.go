@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+// runConnection runs a command with the connection flags and returns what parseConnection read.
+func runConnection(withZenko bool, withStorj bool, args ...string) (connection, error) {
+	var conn connection
+	command := cli.NewApp()
+	command.Flags = connectionFlags(withZenko, withStorj)
+	command.Action = func(cliContext *cli.Context) (err error) {
+		conn, err = parseConnection(cliContext, withZenko, withStorj)
+		return err
+	}
+	err := command.Run(append([]string{"storj-zenko"}, args...))
+	return conn, err
+}
+
+func TestParseConnectionPositional(t *testing.T) {
+	for _, test := range []struct {
+		withZenko bool
+		args      []string
+		want      connection
+	}{
+		{args: []string{"s.json"}, want: connection{storjConfig: "s.json"}},
+		{args: []string{"s.json", "key"}, want: connection{storjConfig: "s.json", deriveScope: true}},
+		{args: []string{"s.json", "key", "restrict"}, want: connection{storjConfig: "s.json", deriveScope: true, restrict: true}},
+		{args: []string{"s.json", "nokey", "restrict"}, want: connection{storjConfig: "s.json"}},
+		// The last argument after "key" decides, like the parse command of earlier versions.
+		{args: []string{"s.json", "key", "other", "restrict"}, want: connection{storjConfig: "s.json", deriveScope: true, restrict: true}},
+		{args: []string{"s.json", "key", "restrict", "other"}, want: connection{storjConfig: "s.json", deriveScope: true}},
+		{args: []string{"s.json", "key", "restrict", "restrict"}, want: connection{storjConfig: "s.json", deriveScope: true, restrict: true}},
+		{withZenko: true, args: []string{"z.json", "s.json", "key", "restrict"}, want: connection{zenkoConfig: "z.json", storjConfig: "s.json", deriveScope: true, restrict: true}},
+		{withZenko: true, args: []string{"--restrict", "z.json", "s.json", "key", "other"}, want: connection{zenkoConfig: "z.json", storjConfig: "s.json", deriveScope: true, restrict: true}},
+	} {
+		conn, err := runConnection(test.withZenko, true, test.args...)
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		if conn != test.want {
+			t.Errorf("%q: read %+v, want %+v", test.args, conn, test.want)
+		}
+	}
+
+	if _, err := runConnection(false, true, "--storj-config", "a.json", "b.json"); err == nil {
+		t.Error("a positional Storj configuration was accepted together with --storj-config")
+	}
+}
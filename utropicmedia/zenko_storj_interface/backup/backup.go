@@ -0,0 +1,438 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/retry"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// Config configures a run of the store command.
+type Config struct {
+	// Source is where the objects are read from.
+	Source Source
+	// Bucket is the Storj bucket the objects are copied to.
+	Bucket storj.Destination
+	// Storj is the Storj configuration, whose upload path prefixes every object.
+	Storj storj.ConfigStorj
+	// Chunking decides how objects are split before they are uploaded.
+	Chunking chunker.Config
+	// Workers is the number of objects transferred at the same time.
+	Workers int
+	// Previous holds the entries of the previous run keyed by "<zenkoBucket>/<key>".
+	// Unchanged objects found in it are referenced instead of transferred again.
+	Previous map[string]storj.ManifestEntry
+	// Checkpoint is the checkpoint of the run being resumed, nil for a new run.
+	Checkpoint *storj.Checkpoint
+	// CheckpointInterval is the minimum time between two checkpoint uploads.
+	CheckpointInterval time.Duration
+	// Filter selects the object keys that are copied.
+	Filter *filter.Filter
+	// Retry is the policy every Zenko and Storj call is retried with.
+	Retry *retry.Policy
+	// ContinueOnError skips objects that fail after all retries and records them
+	// in manifest.Failures, instead of canceling the run.
+	ContinueOnError bool
+}
+
+// Stages of copying an object, reported with every failure.
+const (
+	StageList   = "list"
+	StageStat   = "stat"
+	StageRead   = "read"
+	StageUpload = "upload"
+)
+
+// objectError is an error of copying an object, with the stage that failed.
+type objectError struct {
+	stage string
+	err   error
+}
+
+func (err *objectError) Error() string {
+	return err.stage + ": " + err.err.Error()
+}
+
+// sourceReader remembers the errors of reading a Zenko object,
+// so that they are told apart from the errors of uploading it to Storj.
+type sourceReader struct {
+	reader io.Reader
+	err    error
+}
+
+// Read implements io.Reader.
+func (source *sourceReader) Read(p []byte) (int, error) {
+	n, err := source.reader.Read(p)
+	if err != nil && err != io.EOF {
+		source.err = err
+	}
+	return n, err
+}
+
+// stage returns the stage an error occurred in while the source was uploaded.
+func (source *sourceReader) stage() string {
+	if source.err != nil {
+		return StageRead
+	}
+	return StageUpload
+}
+
+// job is a Zenko object waiting to be copied.
+type job struct {
+	bucket string
+	object zenko.ObjectVersion
+}
+
+// id returns the storj.ObjectID of the object of the job.
+func (job job) id() string {
+	return storj.ObjectID(job.bucket, job.object.Key, job.object.VersionID)
+}
+
+// Run copies every object of the Zenko buckets listed in the manifest to Storj
+// and records them in the manifest. The buckets are listed concurrently and the objects
+// are copied by a pool of workers; the first error cancels the whole run, unless
+// Config.ContinueOnError is set and the failures are recorded in manifest.Failures.
+// Entries are added in the order of manifest.Buckets and, within a bucket, in key order,
+// so the manifest does not depend on the order in which the workers finish.
+//
+// A checkpoint is saved to Storj when the run starts and while it progresses. When the run
+// fails, a final checkpoint is saved and the run can be resumed by passing it in Config.Checkpoint.
+func Run(ctx context.Context, config Config, manifest *storj.Manifest) error {
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	chunkStore := storj.NewChunkStore(config.Bucket, config.Storj.UploadPath)
+	progress := newProgress(config, manifest)
+	parentCtx := ctx
+
+	// A run that is killed before its first periodic checkpoint can still be resumed.
+	if err := progress.save(ctx); err != nil {
+		return fmt.Errorf("could not save checkpoint: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var firstErr error
+	var failures []storj.Failure
+	skip := func(failure storj.Failure) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Printf("Skipping %s after failed %s: %s\n", storj.ObjectID(failure.Bucket, failure.Key, failure.VersionID), failure.Stage, failure.Error)
+		failures = append(failures, failure)
+	}
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	jobs := make(chan job)
+	var listers sync.WaitGroup
+	for _, zenkoBucket := range manifest.Buckets {
+		listers.Add(1)
+		go func(zenkoBucket string) {
+			defer listers.Done()
+			// List the objects selected by the filter from the specified bucket.
+			var lastKey string
+			err := config.Source.List(ctx, zenkoBucket, config.Filter, func(object zenko.ObjectVersion) error {
+				select {
+				case jobs <- job{bucket: zenkoBucket, object: object}:
+					lastKey = object.Key
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && config.ContinueOnError {
+				skip(storj.Failure{Bucket: zenkoBucket, Key: lastKey, Stage: StageList, Error: err.Error()})
+			} else if err != nil {
+				fail(fmt.Errorf("object information error in bucket %s: %v", zenkoBucket, err))
+			}
+		}(zenkoBucket)
+	}
+	go func() {
+		listers.Wait()
+		close(jobs)
+	}()
+
+	var pool sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		pool.Add(1)
+		go func() {
+			defer pool.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				// Skip objects completed before the run was resumed.
+				if entry, ok := progress.done(job.id()); ok && entry.Unchanged(job.object.Size, job.object.ETag, job.object.LastModified) {
+					continue
+				}
+				entry, err := copyObject(ctx, config, chunkStore, progress, manifest, job)
+				if err, ok := err.(*objectError); ok && config.ContinueOnError && ctx.Err() == nil {
+					skip(storj.Failure{Bucket: job.bucket, Key: job.object.Key, VersionID: job.object.VersionID, Stage: err.stage, Error: err.err.Error()})
+					continue
+				}
+				if err != nil {
+					fail(fmt.Errorf("%s: %v", job.id(), err))
+					continue
+				}
+				progress.complete(ctx, entry)
+			}
+		}()
+	}
+	pool.Wait()
+
+	if firstErr != nil {
+		if err := progress.save(parentCtx); err != nil {
+			fmt.Println("Could not save checkpoint:", err)
+		}
+		return firstErr
+	}
+
+	bucketIndex := make(map[string]int, len(manifest.Buckets))
+	for i, zenkoBucket := range manifest.Buckets {
+		bucketIndex[zenkoBucket] = i
+	}
+	manifest.Objects = progress.completed
+	sort.Slice(manifest.Objects, func(i, j int) bool {
+		a, b := manifest.Objects[i], manifest.Objects[j]
+		if a.Bucket != b.Bucket {
+			return bucketIndex[a.Bucket] < bucketIndex[b.Bucket]
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		// Versions of a key are recorded oldest first.
+		if !a.LastModified.Equal(b.LastModified) {
+			return a.LastModified.Before(b.LastModified)
+		}
+		return a.VersionID < b.VersionID
+	})
+	manifest.Failures = failures
+	sort.Slice(manifest.Failures, func(i, j int) bool {
+		a, b := manifest.Failures[i], manifest.Failures[j]
+		if a.Bucket != b.Bucket {
+			return bucketIndex[a.Bucket] < bucketIndex[b.Bucket]
+		}
+		return a.Key < b.Key
+	})
+	return nil
+}
+
+// objectMetadata returns the metadata of the object of a job, such as its headers and tags.
+func objectMetadata(ctx context.Context, config Config, job job) (storj.ObjectMetadata, error) {
+	var metadata storj.ObjectMetadata
+	err := config.Retry.Do(ctx, "stat "+job.id(), func() (err error) {
+		metadata, err = config.Source.Stat(ctx, job.bucket, job.object)
+		return err
+	})
+	if err != nil {
+		return storj.ObjectMetadata{}, &objectError{stage: StageStat, err: err}
+	}
+	return metadata, nil
+}
+
+// copyObject copies a single Zenko object to Storj and returns its manifest entry.
+func copyObject(ctx context.Context, config Config, chunkStore *storj.ChunkStore, progress *progress, manifest *storj.Manifest, job job) (storj.ManifestEntry, error) {
+	object := job.object
+
+	// Reference unchanged objects from the snapshot that holds their data.
+	if previous, ok := config.Previous[job.id()]; ok && previous.Unchanged(object.Size, object.ETag, object.LastModified) {
+		if previous.Reference == "" {
+			previous.Reference = manifest.Base
+		}
+		// A newer version may have replaced the one that was current in the earlier run.
+		previous.IsLatest = object.IsLatest
+		fmt.Println("\nUnchanged, referencing run", previous.Reference, ":", object.Key)
+		return previous, nil
+	}
+
+	chunking := config.Chunking
+	snapshotPrefix := job.bucket + "_" + manifest.RunID + "/"
+	entry := storj.ManifestEntry{
+		Bucket:       job.bucket,
+		Key:          object.Key,
+		Size:         object.Size,
+		ETag:         object.ETag,
+		LastModified: object.LastModified,
+		VersionID:    object.VersionID,
+		IsLatest:     object.IsLatest,
+		DeleteMarker: object.DeleteMarker,
+		Chunking:     chunking.Strategy,
+		Chunks:       chunking.Count(object.Size),
+	}
+
+	// A delete marker has no data, the manifest entry is all there is to keep.
+	if object.DeleteMarker {
+		fmt.Println("\nRecording delete marker", object.VersionID, "of", object.Key)
+		entry.Chunking, entry.Chunks = "", 0
+		return entry, nil
+	}
+
+	// Versions are stored under a key of their own, so that every version of a key is kept.
+	storageKey := object.Key
+	if object.VersionID != "" {
+		storageKey = storj.VersionKey(object.Key, object.VersionID)
+		fmt.Println("\nReading content from the file :", object.Key, "version", object.VersionID)
+	} else {
+		fmt.Println("\nReading content from the file :", object.Key)
+	}
+	name := job.id()
+
+	metadata, err := objectMetadata(ctx, config, job)
+	if err != nil {
+		return storj.ManifestEntry{}, err
+	}
+	entry.ObjectMetadata = metadata
+
+	// openObject returns a readable object starting at offset, which is split according to the chunking strategy.
+	openObject := func(offset int64) (io.ReadCloser, error) {
+		return config.Source.Open(ctx, job.bucket, object, offset)
+	}
+
+	switch chunking.Strategy {
+	case chunker.None:
+		// Stream the whole object into a single Storj object.
+		entry.Prefix = snapshotPrefix + storageKey
+		stage := StageRead
+		err = config.Retry.Do(ctx, "upload "+name, func() error {
+			stage = StageRead
+			objectReader, err := openObject(0)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = objectReader.Close() }()
+			source := &sourceReader{reader: objectReader}
+			err = storj.UploadStream(ctx, config.Bucket, source, entry.Prefix, config.Storj, entry.ObjectMetadata)
+			stage = source.stage()
+			return err
+		})
+		if err != nil {
+			return entry, &objectError{stage: stage, err: err}
+		}
+	case chunker.Content:
+		// Store content-defined chunks under their hash and the chunk list under the key.
+		// A retry reads the object again, chunks stored by the failed attempt are not uploaded twice.
+		list := storj.ChunkList{Metadata: &entry.ObjectMetadata}
+		uploaded := 0
+		stage := StageRead
+		err = config.Retry.Do(ctx, "upload "+name, func() error {
+			list.Chunks, uploaded = nil, 0
+			stage = StageRead
+			objectReader, err := openObject(0)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = objectReader.Close() }()
+
+			splitter := chunker.NewSplitter(objectReader, chunking.Size)
+			for {
+				data, err := splitter.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					stage = StageRead
+					return err
+				}
+				ref, isNew, err := chunkStore.Put(ctx, data)
+				if err != nil {
+					stage = StageUpload
+					return err
+				}
+				if isNew {
+					uploaded++
+				}
+				list.Chunks = append(list.Chunks, ref)
+			}
+			entry.Prefix = snapshotPrefix + storageKey
+			stage = StageUpload
+			return storj.SaveChunkList(ctx, config.Bucket, config.Storj.UploadPath, entry.Prefix, list)
+		})
+		if err != nil {
+			return entry, &objectError{stage: stage, err: err}
+		}
+		entry.ChunkSize = chunking.Size
+		entry.Chunks = len(list.Chunks)
+		fmt.Printf("%s: %d of %d chunks uploaded, %d already stored\n", object.Key, uploaded, len(list.Chunks), len(list.Chunks)-uploaded)
+	default:
+		entry.ChunkSize = chunking.Size
+		entry.Prefix = snapshotPrefix + storj.ChunkPrefix(storageKey)
+
+		// Continue after the chunks uploaded before the run was interrupted.
+		start := progress.resumeFrom(job.id(), object.ETag, chunking.Size, entry.Prefix)
+		if start > 0 {
+			fmt.Printf("Resuming %s from chunk %d of %d\n", object.Key, start, entry.Chunks)
+		}
+
+		// The object is read sequentially and only opened again, at the failed chunk, after an error.
+		var objectReader io.ReadCloser
+		defer func() {
+			if objectReader != nil {
+				_ = objectReader.Close()
+			}
+		}()
+		for i := start; i < entry.Chunks; i++ {
+			zenkoFilePath := entry.Prefix + "/" + storj.ChunkName(i, entry.Extension)
+			stage := StageRead
+			err = config.Retry.Do(ctx, "upload "+zenkoFilePath, func() error {
+				stage = StageRead
+				if objectReader == nil {
+					var err error
+					objectReader, err = openObject(int64(i) * chunking.Size)
+					if err != nil {
+						return err
+					}
+				}
+				// Upload the next section of the Zenko object on storj Network with file name.
+				// The first chunk carries the metadata of the object.
+				var metadata storj.ObjectMetadata
+				if i == 0 {
+					metadata = entry.ObjectMetadata
+				}
+				source := &sourceReader{reader: io.LimitReader(objectReader, chunking.Size)}
+				err := storj.UploadStream(ctx, config.Bucket, source, zenkoFilePath, config.Storj, metadata)
+				if err != nil {
+					stage = source.stage()
+					_ = objectReader.Close()
+					objectReader = nil
+				}
+				return err
+			})
+			if err != nil {
+				return entry, &objectError{stage: stage, err: err}
+			}
+			progress.chunkDone(ctx, storj.PartialObject{
+				Bucket:    job.bucket,
+				Key:       object.Key,
+				VersionID: object.VersionID,
+				ETag:      object.ETag,
+				ChunkSize: chunking.Size,
+				Chunks:    i + 1,
+				Prefix:    entry.Prefix,
+			})
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+// DefaultCheckpointInterval is the default minimum time between two checkpoint uploads.
+const DefaultCheckpointInterval = 30 * time.Second
+
+// progress tracks the objects completed by a run and saves a checkpoint of them after every
+// object uploaded in chunks, and otherwise periodically.
+type progress struct {
+	config   Config
+	manifest *storj.Manifest
+	resumed  map[string]storj.ManifestEntry
+
+	mu        sync.Mutex
+	completed []storj.ManifestEntry
+	index     map[string]int // position in completed, keyed by storj.ObjectID
+	partial   map[string]storj.PartialObject
+	lastSave  time.Time
+
+	saving sync.Mutex
+}
+
+// newProgress returns the progress of a run, seeded from the checkpoint being resumed.
+func newProgress(config Config, manifest *storj.Manifest) *progress {
+	progress := &progress{
+		config:   config,
+		manifest: manifest,
+		resumed:  make(map[string]storj.ManifestEntry),
+		index:    make(map[string]int),
+		partial:  make(map[string]storj.PartialObject),
+		lastSave: time.Now(),
+	}
+	if config.Checkpoint != nil {
+		for _, entry := range config.Checkpoint.Manifest.Objects {
+			progress.resumed[entry.ID()] = entry
+			progress.index[entry.ID()] = len(progress.completed)
+			progress.completed = append(progress.completed, entry)
+		}
+		for _, partial := range config.Checkpoint.Partial {
+			progress.partial[partial.ID()] = partial
+		}
+	}
+	return progress
+}
+
+// done returns the entry of the object with the given storj.ObjectID completed before the run was resumed.
+func (progress *progress) done(id string) (storj.ManifestEntry, bool) {
+	entry, ok := progress.resumed[id]
+	return entry, ok
+}
+
+// resumeFrom returns the number of fixed chunks of an object that were already uploaded to prefix.
+func (progress *progress) resumeFrom(id string, etag string, chunkSize int64, prefix string) int {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	partial, ok := progress.partial[id]
+	if !ok || partial.ETag != etag || partial.ChunkSize != chunkSize || partial.Prefix != prefix {
+		return 0
+	}
+	return partial.Chunks
+}
+
+// chunkDone records that the first partial.Chunks chunks of an object are uploaded.
+func (progress *progress) chunkDone(ctx context.Context, partial storj.PartialObject) {
+	progress.mu.Lock()
+	progress.partial[partial.ID()] = partial
+	progress.mu.Unlock()
+	progress.maybeSave(ctx, false)
+}
+
+// complete records a finished object. It replaces the entry of an object that was completed
+// before the run was resumed and copied again because it changed since. A checkpoint is saved
+// right away for objects uploaded in chunks, which are the most expensive to copy again.
+func (progress *progress) complete(ctx context.Context, entry storj.ManifestEntry) {
+	progress.mu.Lock()
+	if i, ok := progress.index[entry.ID()]; ok {
+		progress.completed[i] = entry
+	} else {
+		progress.index[entry.ID()] = len(progress.completed)
+		progress.completed = append(progress.completed, entry)
+	}
+	delete(progress.partial, entry.ID())
+	progress.mu.Unlock()
+	chunked := entry.Reference == "" && (entry.Chunking == chunker.Fixed || entry.Chunking == chunker.Content)
+	progress.maybeSave(ctx, chunked)
+}
+
+// maybeSave saves a checkpoint when force is set or the checkpoint interval has passed since the last one.
+func (progress *progress) maybeSave(ctx context.Context, force bool) {
+	progress.mu.Lock()
+	due := force || time.Since(progress.lastSave) >= progress.config.CheckpointInterval
+	if due {
+		progress.lastSave = time.Now()
+	}
+	progress.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := progress.save(ctx); err != nil {
+		fmt.Println("Could not save checkpoint:", err)
+	}
+}
+
+// save uploads a checkpoint with the current progress.
+func (progress *progress) save(ctx context.Context) error {
+	progress.saving.Lock()
+	defer progress.saving.Unlock()
+
+	progress.mu.Lock()
+	checkpoint := &storj.Checkpoint{
+		Manifest: *progress.manifest,
+		Chunking: progress.config.Chunking,
+	}
+	checkpoint.Manifest.Objects = append([]storj.ManifestEntry(nil), progress.completed...)
+	for _, partial := range progress.partial {
+		checkpoint.Partial = append(checkpoint.Partial, partial)
+	}
+	progress.mu.Unlock()
+
+	return storj.SaveCheckpoint(ctx, progress.config.Bucket, progress.config.Storj.UploadPath, checkpoint)
+}
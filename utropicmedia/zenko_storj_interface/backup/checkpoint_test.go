@@ -0,0 +1,120 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/backup"
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+func TestResumeReplacesChangedObject(t *testing.T) {
+	ctx := context.Background()
+	source := memory.NewSource()
+	objects := source.Bucket("photos")
+	objects.PutObject("a.txt", []byte("abcd"), storj.ObjectMetadata{})
+	objects.PutObject("b.txt", []byte("efgh"), storj.ObjectMetadata{})
+	bucket := memory.NewBucket()
+
+	config := backup.Config{
+		Source:   source,
+		Bucket:   bucket,
+		Storj:    storj.ConfigStorj{UploadPath: "backup"},
+		Chunking: chunker.Config{Strategy: chunker.Fixed, Size: 2},
+		Workers:  1,
+	}
+	newManifest := func() *storj.Manifest {
+		return &storj.Manifest{RunID: "run", Buckets: []string{"photos"}}
+	}
+
+	// The first run copies a.txt and fails on b.txt.
+	objects.SetFault(func(op string, path string) error {
+		if op == "get" && path == "b.txt" {
+			return errors.New("injected failure")
+		}
+		return nil
+	})
+	if err := backup.Run(ctx, config, newManifest()); err == nil {
+		t.Fatal("run succeeded despite the failure")
+	}
+	objects.SetFault(nil)
+
+	// a.txt changes before the run is resumed.
+	objects.PutObject("a.txt", []byte("abcdefghi"), storj.ObjectMetadata{})
+
+	checkpoint, err := storj.LoadCheckpoint(ctx, bucket, "backup", "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoint.Manifest.Objects) != 1 || checkpoint.Manifest.Objects[0].Key != "a.txt" {
+		t.Fatalf("checkpoint holds %+v, want a.txt", checkpoint.Manifest.Objects)
+	}
+	config.Checkpoint = checkpoint
+	manifest := newManifest()
+	if err := backup.Run(ctx, config, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Objects) != 2 {
+		t.Fatalf("manifest holds %d entries, want 2: %+v", len(manifest.Objects), manifest.Objects)
+	}
+	a, b := manifest.Objects[0], manifest.Objects[1]
+	if a.Key != "a.txt" || a.Size != 9 || a.Chunks != 5 {
+		t.Errorf("a.txt recorded as %q with %d bytes in %d chunks, want 9 bytes in 5 chunks", a.Key, a.Size, a.Chunks)
+	}
+	if b.Key != "b.txt" || b.Size != 4 || b.Chunks != 2 {
+		t.Errorf("b.txt recorded as %q with %d bytes in %d chunks, want 4 bytes in 2 chunks", b.Key, b.Size, b.Chunks)
+	}
+}
+
+func TestCheckpointSaves(t *testing.T) {
+	for _, test := range []struct {
+		chunking chunker.Config
+		// saves is the number of checkpoints a run of three objects saves with a long interval:
+		// one when it starts and one after every object uploaded in chunks.
+		saves int
+	}{
+		{chunker.Config{Strategy: chunker.Fixed, Size: 2}, 4},
+		{chunker.Config{Strategy: chunker.Content, Size: chunker.MinAverageChunkSize}, 4},
+		{chunker.Config{Strategy: chunker.None}, 1},
+	} {
+		t.Run(test.chunking.Strategy, func(t *testing.T) {
+			ctx := context.Background()
+			source := memory.NewSource()
+			for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+				source.Bucket("photos").PutObject(key, []byte("data of "+key), storj.ObjectMetadata{})
+			}
+			bucket := memory.NewBucket()
+			checkpointPath := storj.CheckpointPath("backup", "run")
+			var saves int
+			bucket.SetFault(func(op string, path string) error {
+				if op == "put" && path == checkpointPath {
+					saves++
+				}
+				return nil
+			})
+
+			config := backup.Config{
+				Source:             source,
+				Bucket:             bucket,
+				Storj:              storj.ConfigStorj{UploadPath: "backup"},
+				Chunking:           test.chunking,
+				Workers:            1,
+				CheckpointInterval: time.Hour,
+			}
+			if err := backup.Run(ctx, config, &storj.Manifest{RunID: "run", Buckets: []string{"photos"}}); err != nil {
+				t.Fatal(err)
+			}
+			if saves != test.saves {
+				t.Errorf("saved %d checkpoints, want %d", saves, test.saves)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// Plan summarizes what a run of the store command would do.
+type Plan struct {
+	// Objects and Bytes count the objects that would be transferred.
+	Objects int
+	Bytes   int64
+	// Referenced and ReferencedBytes count the unchanged objects that would be
+	// referenced from an earlier run instead of transferred.
+	Referenced      int
+	ReferencedBytes int64
+	// Completed counts the objects completed before the run being resumed was interrupted.
+	Completed int
+}
+
+// DryRun lists the objects Run would copy and prints, for every object, the Storj path it
+// would be stored under and its chunking. No object data is read and nothing is written
+// to Storj, so Config.Bucket may be nil.
+func DryRun(ctx context.Context, config Config, manifest *storj.Manifest) (Plan, error) {
+	var plan Plan
+	progress := newProgress(config, manifest)
+	uploadPrefix := storj.UploadPrefix(config.Storj.UploadPath)
+
+	for _, zenkoBucket := range manifest.Buckets {
+		fmt.Printf("\nZenko Bucket: %s\n", zenkoBucket)
+		snapshotPrefix := zenkoBucket + "_" + manifest.RunID + "/"
+		err := config.Source.List(ctx, zenkoBucket, config.Filter, func(object zenko.ObjectVersion) error {
+			id := storj.ObjectID(zenkoBucket, object.Key, object.VersionID)
+			name := object.Key
+			if object.VersionID != "" {
+				name += " version " + object.VersionID
+			}
+			if entry, ok := progress.done(id); ok && entry.Unchanged(object.Size, object.ETag, object.LastModified) {
+				plan.Completed++
+				fmt.Printf("  %s (%d bytes): already copied by the interrupted run\n", name, object.Size)
+				return nil
+			}
+			if previous, ok := config.Previous[id]; ok && previous.Unchanged(object.Size, object.ETag, object.LastModified) {
+				reference := previous.Reference
+				if reference == "" {
+					reference = manifest.Base
+				}
+				plan.Referenced++
+				plan.ReferencedBytes += object.Size
+				fmt.Printf("  %s (%d bytes): unchanged, referencing run %s\n", name, object.Size, reference)
+				return nil
+			}
+			if object.DeleteMarker {
+				fmt.Printf("  %s: delete marker, recorded in the manifest\n", name)
+				return nil
+			}
+
+			plan.Objects++
+			plan.Bytes += object.Size
+			storageKey := object.Key
+			if object.VersionID != "" {
+				storageKey = storj.VersionKey(object.Key, object.VersionID)
+			}
+			chunking := config.Chunking
+			switch chunking.Strategy {
+			case chunker.None:
+				fmt.Printf("  %s (%d bytes) -> %s\n", name, object.Size, uploadPrefix+snapshotPrefix+storageKey)
+			case chunker.Content:
+				fmt.Printf("  %s (%d bytes) -> %s: chunk list of content-defined chunks averaging %d bytes, stored once in %s\n",
+					name, object.Size, uploadPrefix+snapshotPrefix+storageKey, chunking.Size, uploadPrefix+storj.ChunkFolder+"/")
+			default:
+				if chunking.Count(object.Size) == 0 {
+					fmt.Printf("  %s (0 bytes): empty, no chunks\n", name)
+					break
+				}
+				fmt.Printf("  %s (%d bytes) -> %s/<0..%d>: %d chunks of up to %d bytes\n",
+					name, object.Size, uploadPrefix+snapshotPrefix+storj.ChunkPrefix(storageKey), chunking.Count(object.Size)-1, chunking.Count(object.Size), chunking.Size)
+			}
+			return nil
+		})
+		if err != nil {
+			return plan, fmt.Errorf("object information error in bucket %s: %v", zenkoBucket, err)
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,445 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/backup"
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/retry"
+	"utropicmedia/zenko_storj_interface/s3test"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// e2e holds an S3 server standing in for Zenko and an in-memory Storj bucket.
+type e2e struct {
+	t      *testing.T
+	ctx    context.Context
+	server *s3test.Server
+	zenko  *zenko.ZenkoReader
+	bucket *memory.Bucket
+}
+
+func newE2E(t *testing.T) *e2e {
+	server := s3test.NewServer()
+	reader, err := server.Connect()
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return &e2e{t: t, ctx: context.Background(), server: server, zenko: reader, bucket: memory.NewBucket()}
+}
+
+func (test *e2e) close() {
+	test.server.Close()
+}
+
+// put writes an object to the S3 server with a content type, user metadata and tags.
+func (test *e2e) put(bucket string, key string, data []byte) {
+	test.t.Helper()
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-test")
+	header.Set("X-Amz-Meta-Owner", "owner of "+key)
+	test.server.PutObject(bucket, key, data, header)
+	if err := test.zenko.Tagger.PutTags(test.ctx, bucket, key, map[string]string{"key": key}); err != nil {
+		test.t.Fatal(err)
+	}
+}
+
+// options returns the options of a run of the store command over the S3 server.
+func (test *e2e) options(chunking chunker.Config) backup.StoreOptions {
+	return backup.StoreOptions{
+		Bucket:   test.bucket,
+		Storj:    storj.ConfigStorj{UploadPath: "backup"},
+		Zenko:    test.zenko,
+		Chunking: chunking,
+		Workers:  2,
+		Retry:    retry.New(3, time.Millisecond, time.Millisecond, 0),
+	}
+}
+
+// store runs the store command and returns the manifest of the run.
+func (test *e2e) store(options backup.StoreOptions) *storj.Manifest {
+	test.t.Helper()
+	config, manifest, err := backup.Prepare(test.ctx, options)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	if err := backup.Store(test.ctx, config, manifest); err != nil {
+		test.t.Fatal(err)
+	}
+	return manifest
+}
+
+// snapshot returns the objects of a Zenko bucket in a run, all their versions when versions is set.
+func (test *e2e) snapshot(manifest *storj.Manifest, bucket string, versions bool) []storj.ChunkedObject {
+	test.t.Helper()
+	snapshot := storj.Snapshot{Bucket: bucket, Time: manifest.Start, Manifest: manifest}
+	var objects []storj.ChunkedObject
+	var err error
+	if versions {
+		objects, err = storj.SnapshotVersions(test.ctx, test.bucket, "backup", snapshot)
+	} else {
+		objects, err = storj.SnapshotObjects(test.ctx, test.bucket, "backup", snapshot)
+	}
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	return objects
+}
+
+// read reassembles the chunks of an object.
+func (test *e2e) read(object storj.ChunkedObject) []byte {
+	test.t.Helper()
+	reader := storj.NewChunkReader(test.ctx, test.bucket, object.Chunks)
+	defer func() { _ = reader.Close() }()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	return data
+}
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, chunking := range []chunker.Config{
+		{Strategy: chunker.Fixed, Size: 64 * 1024},
+		{Strategy: chunker.None},
+		{Strategy: chunker.Content, Size: chunker.MinAverageChunkSize},
+	} {
+		t.Run(chunking.Strategy, func(t *testing.T) {
+			test := newE2E(t)
+			defer test.close()
+			objects := map[string]map[string][]byte{
+				"photos": {
+					"2020/a.jpg":    randomData(1, 300*1024),
+					"2020/b.tar.gz": randomData(2, 70*1024),
+					"small.txt":     []byte("small"),
+				},
+				"docs": {
+					"readme.md": []byte("# readme"),
+				},
+			}
+			for bucket, keys := range objects {
+				for key, data := range keys {
+					test.put(bucket, key, data)
+				}
+			}
+
+			manifest := test.store(test.options(chunking))
+			if !reflect.DeepEqual(manifest.Buckets, []string{"docs", "photos"}) {
+				t.Fatalf("run covers %v, want [docs photos]", manifest.Buckets)
+			}
+
+			for bucket, keys := range objects {
+				snapshot := test.snapshot(manifest, bucket, false)
+				if len(snapshot) != len(keys) {
+					t.Fatalf("snapshot of %s holds %d objects, want %d", bucket, len(snapshot), len(keys))
+				}
+				for _, object := range snapshot {
+					if !bytes.Equal(test.read(object), keys[object.Key]) {
+						t.Errorf("%s/%s is not reassembled to its data", bucket, object.Key)
+					}
+					metadata := object.Metadata
+					if metadata.ContentType != "application/x-test" || metadata.UserMetadata["Owner"] != "owner of "+object.Key || metadata.Tags["key"] != object.Key {
+						t.Errorf("%s/%s recorded with metadata %+v", bucket, object.Key, metadata)
+					}
+				}
+
+				// Restore the snapshot into a new Zenko bucket.
+				target := "restored-" + bucket
+				test.server.MakeBucket(target)
+				destination := &zenko.Destination{Client: test.zenko.Client, Tagger: test.zenko.Tagger, Bucket: target}
+				if err := storj.Restore(test.ctx, test.bucket, snapshot, destination, nil); err != nil {
+					t.Fatal(err)
+				}
+				for key, data := range keys {
+					object, ok := test.server.Object(target, key)
+					if !ok {
+						t.Errorf("%s/%s was not restored", target, key)
+						continue
+					}
+					if !bytes.Equal(object.Data, data) {
+						t.Errorf("%s/%s restored with other data", target, key)
+					}
+					if object.Header.Get("Content-Type") != "application/x-test" || object.Header.Get("X-Amz-Meta-Owner") != "owner of "+key || object.Tags["key"] != key {
+						t.Errorf("%s/%s restored with headers %v and tags %v", target, key, object.Header, object.Tags)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVersionsRoundTrip(t *testing.T) {
+	test := newE2E(t)
+	defer test.close()
+	test.server.EnableVersioning("photos")
+	test.put("photos", "a.txt", []byte("first version"))
+	test.put("photos", "a.txt", []byte("second version"))
+	test.put("photos", "gone.txt", []byte("deleted"))
+	if err := test.zenko.Client.RemoveObject("photos", "gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	options := test.options(chunker.Config{Strategy: chunker.Fixed, Size: 4})
+	options.Versions = true
+	manifest := test.store(options)
+
+	// The current objects leave out the deleted key.
+	current := test.snapshot(manifest, "photos", false)
+	if len(current) != 1 || current[0].Key != "a.txt" || string(test.read(current[0])) != "second version" {
+		t.Fatalf("current objects are %+v, want the second version of a.txt", current)
+	}
+
+	// Every version is kept, oldest first.
+	versions := test.snapshot(manifest, "photos", true)
+	var got []string
+	for _, object := range versions {
+		if object.DeleteMarker {
+			got = append(got, object.Key+": delete marker")
+			continue
+		}
+		got = append(got, object.Key+": "+string(test.read(object)))
+	}
+	want := []string{"a.txt: first version", "a.txt: second version", "gone.txt: deleted", "gone.txt: delete marker"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("versions are %q, want %q", got, want)
+	}
+
+	// Restoring every version into a versioned bucket recreates the history.
+	test.server.EnableVersioning("restored")
+	destination := &zenko.Destination{Client: test.zenko.Client, Tagger: test.zenko.Tagger, Bucket: "restored"}
+	if err := storj.Restore(test.ctx, test.bucket, versions, destination, nil); err != nil {
+		t.Fatal(err)
+	}
+	if keys := test.server.Keys("restored"); !reflect.DeepEqual(keys, []string{"a.txt"}) {
+		t.Errorf("restored bucket holds %v, want [a.txt]", keys)
+	}
+	history := test.server.Versions("restored", "gone.txt")
+	if len(history) != 2 || !history[0].DeleteMarker || string(history[1].Data) != "deleted" {
+		t.Errorf("restored history of gone.txt is %+v, want the data and a delete marker", history)
+	}
+	if object, _ := test.server.Object("restored", "a.txt"); string(object.Data) != "second version" {
+		t.Errorf("a.txt restored as %q, want the second version", object.Data)
+	}
+}
+
+// failFirst returns a fault of the S3 server that fails the first attempts GET requests of key.
+func failFirst(key string, attempts int) s3test.Fault {
+	var mu sync.Mutex
+	return func(r *http.Request, bucket string, requested string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method != http.MethodGet || requested != key || isTagging(r) || attempts == 0 {
+			return nil
+		}
+		attempts--
+		return &s3test.Error{Status: http.StatusGatewayTimeout, Code: "GatewayTimeout", Message: "injected failure"}
+	}
+}
+
+// isTagging reports whether a request reads or writes the tags of an object.
+func isTagging(r *http.Request) bool {
+	_, ok := r.URL.Query()["tagging"]
+	return ok
+}
+
+func TestCollidingKeys(t *testing.T) {
+	test := newE2E(t)
+	defer test.close()
+	// Older versions uploaded the chunks of all these keys below the folder "a".
+	objects := map[string][]byte{
+		"a":     []byte("key a"),
+		"a.a":   []byte("key a.a"),
+		"a.txt": []byte("key a.txt"),
+		"a.md":  []byte("key a.md"),
+		"a/b c": []byte("key a/b c"),
+	}
+	for key, data := range objects {
+		test.put("photos", key, data)
+	}
+
+	manifest := test.store(test.options(chunker.Config{Strategy: chunker.Fixed, Size: 4}))
+	snapshot := test.snapshot(manifest, "photos", false)
+	if len(snapshot) != len(objects) {
+		t.Fatalf("snapshot holds %d objects, want %d", len(snapshot), len(objects))
+	}
+	for _, object := range snapshot {
+		if !bytes.Equal(test.read(object), objects[object.Key]) {
+			t.Errorf("%s is not reassembled to its data", object.Key)
+		}
+	}
+
+	// The keys are recovered from the chunk paths as well.
+	listed, err := storj.ListChunkedObjects(test.ctx, test.bucket, "backup/photos_"+manifest.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, object := range listed {
+		if !bytes.Equal(test.read(object), objects[object.Key]) {
+			t.Errorf("%s is not reassembled to its data from the chunk paths", object.Key)
+		}
+	}
+	if len(listed) != len(objects) {
+		t.Errorf("chunk paths hold %d objects, want %d", len(listed), len(objects))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	test := newE2E(t)
+	defer test.close()
+	data := randomData(3, 10)
+	test.put("photos", "a.bin", data)
+
+	// Reading from Zenko fails twice, and so does the upload of the second chunk to Storj.
+	test.server.SetFault(failFirst("a.bin", 2))
+	var mu sync.Mutex
+	uploadFailures := 2
+	test.bucket.SetFault(func(op string, path string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if op == "put" && strings.HasSuffix(path, "/a.bin/1") && uploadFailures > 0 {
+			uploadFailures--
+			return errors.New("injected failure")
+		}
+		return nil
+	})
+
+	options := test.options(chunker.Config{Strategy: chunker.Fixed, Size: 4})
+	manifest := test.store(options)
+	if retries := options.Retry.Retries(); retries != 4 {
+		t.Errorf("retried %d calls, want 4", retries)
+	}
+	snapshot := test.snapshot(manifest, "photos", false)
+	if len(snapshot) != 1 || len(snapshot[0].Chunks) != 3 || !bytes.Equal(test.read(snapshot[0]), data) {
+		t.Errorf("snapshot holds %+v, want a.bin in 3 chunks", snapshot)
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	test := newE2E(t)
+	defer test.close()
+	test.put("photos", "a.txt", []byte("a"))
+	test.put("photos", "bad.txt", []byte("bad"))
+	test.put("photos", "c.txt", []byte("c"))
+	test.server.SetFault(func(r *http.Request, bucket string, key string) error {
+		if r.Method == http.MethodGet && key == "bad.txt" && !isTagging(r) {
+			return &s3test.Error{Status: http.StatusForbidden, Code: "AccessDenied", Message: "injected failure"}
+		}
+		return nil
+	})
+
+	// Without --continue-on-error the run stops and keeps its checkpoint.
+	options := test.options(chunker.Config{Strategy: chunker.None})
+	config, manifest, err := backup.Prepare(test.ctx, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Store(test.ctx, config, manifest); err == nil || !strings.Contains(err.Error(), "bad.txt") {
+		t.Fatalf("run returned %v, want the failure of bad.txt", err)
+	}
+	if _, ok := test.bucket.Object(storj.CheckpointPath("backup", manifest.RunID)); !ok {
+		t.Error("the failed run left no checkpoint")
+	}
+
+	// With it the run completes and records the failure.
+	time.Sleep(time.Second)
+	options.ContinueOnError = true
+	manifest = test.store(options)
+	want := []storj.Failure{{Bucket: "photos", Key: "bad.txt", Stage: backup.StageRead}}
+	if len(manifest.Failures) != 1 {
+		t.Fatalf("run records failures %+v, want %+v", manifest.Failures, want)
+	}
+	manifest.Failures[0].Error = ""
+	if !reflect.DeepEqual(manifest.Failures, want) {
+		t.Errorf("run records failures %+v, want %+v", manifest.Failures, want)
+	}
+	var keys []string
+	for _, object := range test.snapshot(manifest, "photos", false) {
+		keys = append(keys, object.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a.txt", "c.txt"}) {
+		t.Errorf("snapshot holds %v, want [a.txt c.txt]", keys)
+	}
+}
+
+func TestResume(t *testing.T) {
+	test := newE2E(t)
+	defer test.close()
+	small := []byte("small")
+	big := randomData(4, 20)
+	test.put("photos", "a.txt", small)
+	test.put("photos", "big.bin", big)
+
+	// The upload of the fourth chunk of big.bin fails for good.
+	var mu sync.Mutex
+	var uploads []string
+	failing := true
+	test.bucket.SetFault(func(op string, path string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if op != "put" || !strings.HasPrefix(path, "backup/photos_") {
+			return nil
+		}
+		if failing && strings.HasSuffix(path, "/big.bin/3") {
+			return errors.New("injected failure")
+		}
+		uploads = append(uploads, strings.TrimPrefix(path, "backup/photos_"))
+		return nil
+	})
+
+	options := test.options(chunker.Config{Strategy: chunker.Fixed, Size: 4})
+	options.Workers = 1
+	options.Retry = nil
+	config, manifest, err := backup.Prepare(test.ctx, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Store(test.ctx, config, manifest); err == nil {
+		t.Fatal("run succeeded despite the failure")
+	}
+	runID := manifest.RunID
+
+	// The resumed run only uploads the missing chunks.
+	mu.Lock()
+	failing = false
+	uploads = nil
+	mu.Unlock()
+	options.Resume = "photos_" + runID
+	manifest = test.store(options)
+	if manifest.RunID != runID {
+		t.Fatalf("resumed run %s, want %s", manifest.RunID, runID)
+	}
+	want := []string{runID + "/big.bin/3", runID + "/big.bin/4"}
+	if !reflect.DeepEqual(uploads, want) {
+		t.Errorf("resumed run uploaded %v, want %v", uploads, want)
+	}
+	if _, ok := test.bucket.Object(storj.CheckpointPath("backup", runID)); ok {
+		t.Error("the checkpoint of the finished run was not removed")
+	}
+
+	snapshot := test.snapshot(manifest, "photos", false)
+	if len(snapshot) != 2 || !bytes.Equal(test.read(snapshot[0]), small) || !bytes.Equal(test.read(snapshot[1]), big) {
+		t.Errorf("snapshot holds %+v, want a.txt and big.bin", snapshot)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"utropicmedia/zenko_storj_interface/retry"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+// MigrateConfig configures a run of the migrate command, which copies plain Storj objects to Zenko.
+type MigrateConfig struct {
+	// Bucket is the Storj bucket the objects are read from.
+	Bucket storj.Destination
+	// Prefix is the Storj folder whose objects are copied; it is removed from their paths to get
+	// the Zenko keys. A missing trailing slash is added.
+	Prefix string
+	// Target is the Zenko bucket the objects are written to, such as a zenko.Destination.
+	Target storj.Destination
+	// SkipExisting skips objects whose key already exists in Target with the same size.
+	SkipExisting bool
+	// DryRun only lists the objects that would be copied.
+	DryRun bool
+	// Workers is the number of objects transferred at the same time.
+	Workers int
+	// Retry is the policy every Zenko and Storj call is retried with.
+	Retry *retry.Policy
+}
+
+// MigrateResult counts the objects of a migration.
+type MigrateResult struct {
+	// Objects and Bytes count the objects copied, or that would be copied in a dry run.
+	Objects int
+	Bytes   int64
+	// Skipped counts the objects that already existed in the Zenko bucket.
+	Skipped int
+}
+
+// Migrate copies every Storj object below MigrateConfig.Prefix into a Zenko bucket under the same
+// key, with the content type, HTTP headers, user metadata and tags stored with it. The objects are
+// copied as they are: chunked backups written by the store command are not reassembled, use restore
+// for them. The objects are copied by a pool of workers and the first error cancels the migration.
+func Migrate(ctx context.Context, config MigrateConfig) (MigrateResult, error) {
+	var result MigrateResult
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	// Without a trailing slash the keys would start with "/".
+	config.Prefix = storj.UploadPrefix(config.Prefix)
+
+	var items []storj.ObjectInfo
+	err := config.Retry.Do(ctx, "list "+config.Prefix, func() (err error) {
+		items, err = config.Bucket.List(ctx, config.Prefix, true)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var firstErr error
+	paths := make(chan string)
+	go func() {
+		defer close(paths)
+		for _, item := range items {
+			if item.IsPrefix {
+				continue
+			}
+			select {
+			case paths <- item.Path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var pool sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		pool.Add(1)
+		go func() {
+			defer pool.Done()
+			for path := range paths {
+				if ctx.Err() != nil {
+					continue
+				}
+				size, copied, err := migrateObject(ctx, config, path)
+				mu.Lock()
+				switch {
+				case err != nil && firstErr == nil && ctx.Err() == nil:
+					firstErr = fmt.Errorf("%s: %v", path, err)
+					cancel()
+				case err == nil && copied:
+					result.Objects++
+					result.Bytes += size
+				case err == nil:
+					result.Skipped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	pool.Wait()
+	return result, firstErr
+}
+
+// migrateObject copies a single Storj object to Zenko. It returns the size of the object
+// and whether it was copied, or would be in a dry run.
+func migrateObject(ctx context.Context, config MigrateConfig, path string) (int64, bool, error) {
+	key := strings.TrimPrefix(path, config.Prefix)
+	if key == "" {
+		return 0, false, fmt.Errorf("the prefix is the whole path, there is no key left")
+	}
+
+	// Get returns the metadata stored with the object, which is not part of the listing.
+	var info storj.ObjectInfo
+	err := config.Retry.Do(ctx, "open "+path, func() error {
+		var reader io.ReadCloser
+		var err error
+		info, reader, err = config.Bucket.Get(ctx, path, 0)
+		if err != nil {
+			return err
+		}
+		return reader.Close()
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	size := info.Size
+
+	if config.SkipExisting {
+		var exists bool
+		err := config.Retry.Do(ctx, "stat "+key, func() error {
+			existing, reader, err := config.Target.Get(ctx, key, 0)
+			if errors.Is(err, storj.ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			exists = existing.Size == size
+			return reader.Close()
+		})
+		if err != nil {
+			return size, false, err
+		}
+		if exists {
+			fmt.Println("Skipping existing object: ", key)
+			return size, false, nil
+		}
+	}
+
+	if config.DryRun {
+		fmt.Printf("  %s (%d bytes) -> %s\n", path, size, key)
+		return size, true, nil
+	}
+
+	fmt.Printf("\nMigrating %s (%d bytes) to %s...\n", path, size, key)
+	err = config.Retry.Do(ctx, "migrate "+path, func() error {
+		// Every attempt reads the object from the start.
+		_, reader, err := config.Bucket.Get(ctx, path, 0)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = reader.Close() }()
+		return config.Target.Put(ctx, key, reader, size, info.Metadata)
+	})
+	if err != nil {
+		return size, false, err
+	}
+	return size, true, nil
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"utropicmedia/zenko_storj_interface/backup"
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+func TestMigratePrefix(t *testing.T) {
+	for _, prefix := range []string{"backups", "backups/"} {
+		t.Run(prefix, func(t *testing.T) {
+			ctx := context.Background()
+			bucket := memory.NewBucket()
+			bucket.PutObject("backups/a.txt", []byte("a"), storj.ObjectMetadata{})
+			bucket.PutObject("backups/sub/b.txt", []byte("bb"), storj.ObjectMetadata{})
+			bucket.PutObject("other/c.txt", []byte("ccc"), storj.ObjectMetadata{})
+			target := memory.NewBucket()
+
+			result, err := backup.Migrate(ctx, backup.MigrateConfig{Bucket: bucket, Prefix: prefix, Target: target})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Objects != 2 || result.Bytes != 3 {
+				t.Errorf("migrated %d objects of %d bytes, want 2 of 3 bytes", result.Objects, result.Bytes)
+			}
+			if paths := target.Paths(); !reflect.DeepEqual(paths, []string{"a.txt", "sub/b.txt"}) {
+				t.Errorf("migrated to %q, want [a.txt sub/b.txt]", paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup
+
+import (
+	"context"
+	"io"
+
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/localfs"
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// Source is where the store and sync commands read the objects they copy from, such as
+// the buckets of a Zenko instance (zenko.Source) or a local directory (localfs.Source).
+type Source interface {
+	// List calls fn for every object of the bucket selected by keys, in key order.
+	List(ctx context.Context, bucket string, keys *filter.Filter, fn func(zenko.ObjectVersion) error) error
+	// Stat returns the metadata of an object, which is not part of the listing.
+	Stat(ctx context.Context, bucket string, object zenko.ObjectVersion) (storj.ObjectMetadata, error)
+	// Open returns the data of an object starting at offset.
+	Open(ctx context.Context, bucket string, object zenko.ObjectVersion, offset int64) (io.ReadCloser, error)
+}
+
+var (
+	_ Source = (*zenko.Source)(nil)
+	_ Source = (*localfs.Source)(nil)
+	_ Source = (*memory.Source)(nil)
+)
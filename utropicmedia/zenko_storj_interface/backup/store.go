@@ -0,0 +1,236 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/localfs"
+	"utropicmedia/zenko_storj_interface/retry"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// StoreOptions are the settings of a run of the store command.
+type StoreOptions struct {
+	// Bucket is the Storj bucket the run is written to. It is nil in a dry run when the
+	// bucket does not exist yet.
+	Bucket storj.Destination
+	// Storj is the Storj configuration, whose upload path prefixes every object.
+	Storj storj.ConfigStorj
+	// Zenko is the connection the objects are read from. It is not used when Dir is set.
+	Zenko *zenko.ZenkoReader
+	// Dir is a local directory whose files are backed up instead of the Zenko buckets.
+	Dir string
+	// Name is the name the files of Dir are recorded under, the name of the directory when empty.
+	Name string
+	// Resume is the interrupted run to continue, "<time>" or "<zenkoBucket>_<time>".
+	// A resumed run keeps the source, chunking, filter and versions it was started with.
+	Resume string
+	// Incremental references the objects unchanged since the previous run instead of copying them.
+	Incremental bool
+	// Versions copies every version and delete marker of versioned buckets.
+	Versions bool
+	// NoTags leaves out the tags of Zenko objects, saving one request per object.
+	NoTags bool
+	// Filter selects the buckets and keys that are copied.
+	Filter filter.Config
+	// Chunking decides how objects are split before they are uploaded.
+	Chunking chunker.Config
+	// Workers is the number of objects transferred at the same time.
+	Workers int
+	// Retry is the policy every Zenko and Storj call is retried with.
+	Retry *retry.Policy
+	// ContinueOnError skips objects that fail after all retries, see Config.ContinueOnError.
+	ContinueOnError bool
+	// Version is the version of the program, recorded in the manifest.
+	Version string
+}
+
+// Prepare returns the configuration and the manifest of a run of the store command, ready
+// for Store or DryRun. A new run lists the Zenko buckets selected by the filter and, when it
+// is incremental, loads the manifest of the previous run to compare against. A resumed run
+// loads its checkpoint instead.
+func Prepare(ctx context.Context, options StoreOptions) (Config, *storj.Manifest, error) {
+	config := Config{
+		Bucket:             options.Bucket,
+		Storj:              options.Storj,
+		Chunking:           options.Chunking,
+		Workers:            options.Workers,
+		Previous:           make(map[string]storj.ManifestEntry),
+		CheckpointInterval: DefaultCheckpointInterval,
+		Retry:              options.Retry,
+		ContinueOnError:    options.ContinueOnError,
+	}
+	policy := options.Retry
+
+	// Files of a local directory are backed up without Zenko.
+	dir := options.Dir
+	if dir != "" {
+		if options.Versions {
+			return config, nil, fmt.Errorf("--versions cannot be used with --source-dir, files have no versions")
+		}
+		var err error
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return config, nil, err
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return config, nil, err
+		} else if !info.IsDir() {
+			return config, nil, fmt.Errorf("%s is not a directory", dir)
+		}
+		fmt.Println("Backing up directory:", dir)
+	}
+
+	var manifest *storj.Manifest
+	filterRules := options.Filter
+	if options.Resume != "" {
+		// Continue an interrupted run under its original snapshot.
+		runID := options.Resume
+		if _, timestamp, ok := storj.ParseSnapshotName(runID); ok {
+			runID = timestamp.Format(storj.SnapshotTimeFormat)
+		}
+		err := policy.Do(ctx, "load checkpoint", func() (err error) {
+			config.Checkpoint, err = storj.LoadCheckpoint(ctx, options.Bucket, options.Storj.UploadPath, runID)
+			return err
+		})
+		if err != nil {
+			return config, nil, fmt.Errorf("load checkpoint: %v", err)
+		}
+		manifest = &config.Checkpoint.Manifest
+		config.Chunking = config.Checkpoint.Chunking
+		filterRules = filter.Config{}
+		if manifest.Filter != nil {
+			filterRules = *manifest.Filter
+		}
+		if manifest.Dir != dir {
+			return config, nil, fmt.Errorf("run %s read from %q, resume it with the same --source-dir", manifest.RunID, manifest.Dir)
+		}
+		fmt.Printf("Resuming run %s: %d objects already completed\n", manifest.RunID, len(manifest.Objects))
+	}
+	keys, err := filter.New(filterRules)
+	if err != nil {
+		return config, nil, err
+	}
+	config.Filter = keys
+
+	if manifest == nil {
+		t := time.Now()
+		manifest = &storj.Manifest{
+			RunID:    t.Format(storj.SnapshotTimeFormat),
+			Version:  options.Version,
+			Start:    t,
+			Versions: options.Versions,
+			Dir:      dir,
+		}
+		if !filterRules.IsEmpty() {
+			manifest.Filter = &filterRules
+		}
+		if dir != "" {
+			name := options.Name
+			if name == "" {
+				name = filepath.Base(dir)
+			}
+			manifest.Buckets = []string{name}
+		} else {
+			// List of all buckets from Zenko orbit.
+			var buckets []minio.BucketInfo
+			err := policy.Do(ctx, "list buckets", func() (err error) {
+				buckets, err = options.Zenko.Client.ListBuckets()
+				return err
+			})
+			if err != nil {
+				return config, nil, fmt.Errorf("list buckets: %v", err)
+			}
+			for _, zenkoBucket := range buckets {
+				if keys.Bucket(zenkoBucket.Name) {
+					manifest.Buckets = append(manifest.Buckets, zenkoBucket.Name)
+				}
+			}
+		}
+	}
+
+	// Compare against the previous run in incremental mode.
+	switch {
+	case config.Checkpoint != nil && manifest.Base != "":
+		var previous *storj.Manifest
+		err := policy.Do(ctx, "load manifest", func() (err error) {
+			previous, err = storj.LoadManifest(ctx, options.Bucket, options.Storj.UploadPath, manifest.Base)
+			return err
+		})
+		if err != nil {
+			return config, nil, fmt.Errorf("load manifest: %v", err)
+		}
+		if previous != nil {
+			config.Previous = previous.Index()
+		}
+	case config.Checkpoint == nil && options.Incremental && options.Bucket == nil:
+		fmt.Println("No previous run found, copying all objects.")
+	case config.Checkpoint == nil && options.Incremental:
+		var previous *storj.Manifest
+		err := policy.Do(ctx, "load manifest", func() (err error) {
+			previous, err = storj.LatestManifest(ctx, options.Bucket, options.Storj.UploadPath)
+			return err
+		})
+		if err != nil {
+			return config, nil, fmt.Errorf("load manifest: %v", err)
+		}
+		if previous != nil {
+			fmt.Println("Incremental backup based on run: ", previous.RunID)
+			manifest.Base = previous.RunID
+			config.Previous = previous.Index()
+		} else {
+			fmt.Println("No previous run found, copying all objects.")
+		}
+	}
+
+	// Read the files of the directory, or the objects of the Zenko buckets.
+	if manifest.Dir != "" {
+		config.Source = &localfs.Source{Root: manifest.Dir}
+	} else {
+		source := &zenko.Source{Client: options.Zenko.Client, Tagger: options.Zenko.Tagger, Retry: policy}
+		if options.NoTags {
+			source.Tagger = nil
+		}
+		// A resumed run keeps listing versions if it was started with them.
+		if manifest.Versions {
+			source.Versions = options.Zenko.Versions
+		}
+		config.Source = source
+	}
+	return config, manifest, nil
+}
+
+// Store copies the objects of a prepared run with Run, records the run by saving its manifest
+// and removes its checkpoint. When Run fails the checkpoint is kept, so that the run can be
+// resumed with StoreOptions.Resume set to manifest.RunID.
+func Store(ctx context.Context, config Config, manifest *storj.Manifest) error {
+	if err := Run(ctx, config, manifest); err != nil {
+		return err
+	}
+
+	// Record what this run copied.
+	manifest.End = time.Now()
+	err := config.Retry.Do(ctx, "save manifest", func() error {
+		return storj.SaveManifest(ctx, config.Bucket, config.Storj.UploadPath, manifest)
+	})
+	if err != nil {
+		return fmt.Errorf("save manifest: %v", err)
+	}
+
+	// The run is complete, its checkpoint is no longer needed.
+	if err := storj.DeleteCheckpoint(ctx, config.Bucket, config.Storj.UploadPath, manifest.RunID); err != nil {
+		fmt.Println("Could not delete checkpoint:", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/backup"
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+func TestStoreDirectory(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	files := map[string]string{"a.txt": "hello world", "sub/b.bin": "0123456789"}
+	for key, data := range files {
+		fileName := filepath.Join(dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fileName, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bucket := memory.NewBucket()
+	options := backup.StoreOptions{
+		Bucket:   bucket,
+		Storj:    storj.ConfigStorj{UploadPath: "backup"},
+		Dir:      dir,
+		Name:     "files",
+		Chunking: chunker.Config{Strategy: chunker.Fixed, Size: 4},
+	}
+	config, manifest, err := backup.Prepare(ctx, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Store(ctx, config, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := storj.LoadManifest(ctx, bucket, "backup", manifest.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Buckets) != 1 || saved.Buckets[0] != "files" || len(saved.Objects) != 2 {
+		t.Fatalf("manifest records %v with %d objects, want [files] with 2", saved.Buckets, len(saved.Objects))
+	}
+	if _, ok := bucket.Object(storj.CheckpointPath("backup", manifest.RunID)); ok {
+		t.Error("the checkpoint of the finished run was not removed")
+	}
+	restored := restoreToMemory(t, bucket, "backup", storj.Snapshot{Bucket: "files", Time: manifest.Start, Manifest: saved})
+	for key, data := range files {
+		if restored[key] != data {
+			t.Errorf("%s restored as %q, want %q", key, restored[key], data)
+		}
+	}
+
+	// An incremental run, a second later so that it gets a run ID of its own, references
+	// the unchanged files instead of copying them again.
+	time.Sleep(time.Second)
+	options.Incremental = true
+	config, next, err := backup.Prepare(ctx, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Base != manifest.RunID {
+		t.Fatalf("incremental run is based on %q, want %q", next.Base, manifest.RunID)
+	}
+	if err := backup.Store(ctx, config, next); err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Objects) != 2 {
+		t.Fatalf("incremental run records %d objects, want 2", len(next.Objects))
+	}
+	for _, entry := range next.Objects {
+		if entry.Reference != manifest.RunID {
+			t.Errorf("%s references %q, want %q", entry.Key, entry.Reference, manifest.RunID)
+		}
+	}
+}
+
+func TestPrepareRejectsFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	_ = file.Close()
+
+	_, _, err = backup.Prepare(context.Background(), backup.StoreOptions{
+		Bucket:   memory.NewBucket(),
+		Dir:      file.Name(),
+		Chunking: chunker.Config{Strategy: chunker.None},
+	})
+	if err == nil {
+		t.Error("Prepare accepted a file as the source directory")
+	}
+}
+
+// restoreToMemory restores a snapshot and returns the data of every key.
+func restoreToMemory(t *testing.T, bucket storj.Destination, uploadPath string, snapshot storj.Snapshot) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	objects, err := storj.SnapshotObjects(ctx, bucket, uploadPath, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := memory.NewBucket()
+	if err := storj.Restore(ctx, bucket, objects, target, nil); err != nil {
+		t.Fatal(err)
+	}
+	restored := make(map[string]string)
+	for _, path := range target.Paths() {
+		object, _ := target.Object(path)
+		restored[path] = string(object.Data)
+	}
+	return restored
+}
@@ -0,0 +1,254 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// DefaultDeletePercent is the default share of the mirrored objects a sync may delete.
+const DefaultDeletePercent = 10
+
+// SyncPlan is what a sync changes in the mirror of one Zenko bucket.
+type SyncPlan struct {
+	Bucket string
+	// Upload lists the objects that are new or changed since the last sync, in key order.
+	Upload      []minio.ObjectInfo
+	UploadBytes int64
+	// Delete lists the keys of mirrored objects that are no longer in the Zenko bucket.
+	Delete []string
+	// Mirrored counts the objects stored in the mirror before the sync.
+	Mirrored int
+	// unchanged holds the entries of the objects that are already mirrored.
+	unchanged []storj.ManifestEntry
+	// kept holds the entries of mirrored objects the filter does not select. They are
+	// neither uploaded nor deleted, and stay in the mirror state.
+	kept []storj.ManifestEntry
+}
+
+// Unchanged returns the number of objects that are already mirrored.
+func (plan SyncPlan) Unchanged() int {
+	return len(plan.unchanged)
+}
+
+// DeleteLimit protects a mirror from mass deletions, for example when the Zenko bucket
+// was emptied by mistake or a filter selects far fewer keys than intended.
+type DeleteLimit struct {
+	// Max is the number of objects a sync may delete, 0 for no limit.
+	Max int
+	// Percent is the share of the mirrored objects a sync may delete; 100 allows deleting all of them.
+	Percent float64
+}
+
+// Check returns an error when the plan deletes more objects than the limit allows.
+func (limit DeleteLimit) Check(plan SyncPlan) error {
+	deletions := len(plan.Delete)
+	if deletions == 0 {
+		return nil
+	}
+	if limit.Max > 0 && deletions > limit.Max {
+		return fmt.Errorf("refusing to delete %d of %d mirrored objects of bucket %s, the limit is %d objects",
+			deletions, plan.Mirrored, plan.Bucket, limit.Max)
+	}
+	if percent := 100 * float64(deletions) / float64(plan.Mirrored); percent > limit.Percent {
+		return fmt.Errorf("refusing to delete %d of %d mirrored objects of bucket %s (%.1f%%), the limit is %g%%",
+			deletions, plan.Mirrored, plan.Bucket, percent, limit.Percent)
+	}
+	return nil
+}
+
+// PlanSync compares a Zenko bucket with its mirror and returns the objects to upload and delete.
+// An object is uploaded when it is missing from the mirror or its size, ETag or last modification
+// time differ from the state of the last sync. Only objects selected by Config.Filter are
+// compared: a mirrored object is deleted when its key is selected and no longer in the Zenko
+// bucket, mirrored objects outside the filter are left alone. Nothing is changed; without
+// Config.Bucket the mirror is taken to be empty.
+func PlanSync(ctx context.Context, config Config, zenkoBucket string, state *storj.MirrorState) (SyncPlan, error) {
+	plan := SyncPlan{Bucket: zenkoBucket}
+
+	var keys []string
+	err := config.Retry.Do(ctx, "list mirror of "+zenkoBucket, func() (err error) {
+		if config.Bucket == nil {
+			return nil
+		}
+		keys, err = storj.ListMirrorKeys(ctx, config.Bucket, config.Storj.UploadPath, zenkoBucket)
+		return err
+	})
+	if err != nil {
+		return plan, err
+	}
+	plan.Mirrored = len(keys)
+	stored := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		stored[key] = true
+	}
+
+	// Objects are listed with the key rules only, so that objects excluded by their size or
+	// modification time are known to exist and not deleted.
+	previous := state.Index()
+	seen := make(map[string]bool)
+	err = config.Source.List(ctx, zenkoBucket, config.Filter.Keys(), func(object zenko.ObjectVersion) error {
+		seen[object.Key] = true
+		if !config.Filter.Object(object.Key, object.Size, object.LastModified) {
+			if entry, ok := previous[object.Key]; ok && stored[object.Key] {
+				plan.kept = append(plan.kept, entry)
+			}
+			return nil
+		}
+		if entry, ok := previous[object.Key]; ok && stored[object.Key] && entry.Unchanged(object.Size, object.ETag, object.LastModified) {
+			plan.unchanged = append(plan.unchanged, entry)
+			return nil
+		}
+		plan.Upload = append(plan.Upload, object.ObjectInfo)
+		plan.UploadBytes += object.Size
+		return nil
+	})
+	if err != nil {
+		return plan, fmt.Errorf("object information error in bucket %s: %v", zenkoBucket, err)
+	}
+
+	selected := config.Filter.Bucket(zenkoBucket)
+	for _, key := range keys {
+		switch {
+		case seen[key]:
+		case selected && config.Filter.Key(key):
+			plan.Delete = append(plan.Delete, key)
+		default:
+			if entry, ok := previous[key]; ok {
+				plan.kept = append(plan.kept, entry)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// Sync carries out a plan: it uploads the new and changed objects, each as a single Storj
+// object below storj.MirrorPrefix with its metadata, and then deletes the objects that are
+// no longer in the Zenko bucket. The objects are uploaded by a pool of Config.Workers and
+// the first error cancels the sync. State is updated with the mirrored objects in any case,
+// so that it can be saved and the next sync does not upload them again.
+func Sync(ctx context.Context, config Config, plan SyncPlan, state *storj.MirrorState) error {
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	mirrorFolder := storj.MirrorFolder + "/" + plan.Bucket + "/"
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var firstErr error
+	index := make(map[string]storj.ManifestEntry, len(plan.kept)+len(plan.unchanged)+len(plan.Upload))
+	for _, entry := range plan.kept {
+		index[entry.Key] = entry
+	}
+	for _, entry := range plan.unchanged {
+		index[entry.Key] = entry
+	}
+	defer func() {
+		state.Bucket = plan.Bucket
+		state.Updated = time.Now()
+		state.SetObjects(index)
+	}()
+
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for _, object := range plan.Upload {
+			select {
+			case jobs <- job{bucket: plan.Bucket, object: zenko.ObjectVersion{ObjectInfo: object}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var pool sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		pool.Add(1)
+		go func() {
+			defer pool.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				entry, err := mirrorObject(ctx, config, mirrorFolder, job)
+				mu.Lock()
+				if err != nil && firstErr == nil && ctx.Err() == nil {
+					firstErr = fmt.Errorf("%s: %v", job.id(), err)
+					cancel()
+				} else if err == nil {
+					index[entry.Key] = entry
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	pool.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Only delete once every current object is mirrored.
+	for _, key := range plan.Delete {
+		fmt.Println("\nDeleting from the mirror, no longer in Zenko :", key)
+		err := config.Retry.Do(ctx, "delete "+mirrorFolder+key, func() error {
+			return config.Bucket.Delete(ctx, storj.UploadPrefix(config.Storj.UploadPath)+mirrorFolder+key)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: delete: %v", storj.ObjectID(plan.Bucket, key, ""), err)
+		}
+	}
+	return nil
+}
+
+// mirrorObject uploads a Zenko object as a single Storj object below mirrorFolder and returns its entry.
+func mirrorObject(ctx context.Context, config Config, mirrorFolder string, job job) (storj.ManifestEntry, error) {
+	object := job.object
+	fmt.Println("\nMirroring the file :", object.Key)
+
+	metadata, err := objectMetadata(ctx, config, job)
+	if err != nil {
+		return storj.ManifestEntry{}, err
+	}
+	entry := storj.ManifestEntry{
+		Bucket:         job.bucket,
+		Key:            object.Key,
+		Size:           object.Size,
+		ETag:           object.ETag,
+		LastModified:   object.LastModified,
+		ObjectMetadata: metadata,
+		Chunking:       chunker.None,
+		Chunks:         1,
+		Prefix:         mirrorFolder + object.Key,
+	}
+
+	stage := StageRead
+	err = config.Retry.Do(ctx, "upload "+job.id(), func() error {
+		stage = StageRead
+		objectReader, err := config.Source.Open(ctx, job.bucket, object, 0)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = objectReader.Close() }()
+		source := &sourceReader{reader: objectReader}
+		err = storj.UploadStream(ctx, config.Bucket, source, entry.Prefix, config.Storj, entry.ObjectMetadata)
+		stage = source.stage()
+		return err
+	})
+	if err != nil {
+		return entry, &objectError{stage: stage, err: err}
+	}
+	return entry, nil
+}
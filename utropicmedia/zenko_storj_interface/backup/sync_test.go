@@ -0,0 +1,85 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package backup_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"utropicmedia/zenko_storj_interface/backup"
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+func TestSyncFilterKeepsUnselectedKeys(t *testing.T) {
+	ctx := context.Background()
+	source := memory.NewSource()
+	objects := source.Bucket("photos")
+	for _, key := range []string{"keep/1", "keep/2", "other/1", "other/2", "other/3"} {
+		objects.PutObject(key, []byte("data of "+key), storj.ObjectMetadata{})
+	}
+	config := backup.Config{
+		Source: source,
+		Bucket: memory.NewBucket(),
+		Storj:  storj.ConfigStorj{UploadPath: "backup"},
+	}
+
+	sync := func(config backup.Config) backup.SyncPlan {
+		t.Helper()
+		state, err := storj.LoadMirrorState(ctx, config.Bucket, "backup", "photos")
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := backup.PlanSync(ctx, config, "photos", state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := backup.Sync(ctx, config, plan, state); err != nil {
+			t.Fatal(err)
+		}
+		if err := storj.SaveMirrorState(ctx, config.Bucket, "backup", state); err != nil {
+			t.Fatal(err)
+		}
+		return plan
+	}
+	// Mirror everything.
+	if plan := sync(config); len(plan.Upload) != 5 || len(plan.Delete) != 0 {
+		t.Fatalf("first sync uploads %d and deletes %v, want 5 uploads", len(plan.Upload), plan.Delete)
+	}
+
+	// A filtered sync only deletes selected keys that are gone from Zenko.
+	if err := objects.Delete(ctx, "keep/2"); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := filter.New(filter.Config{Include: filter.Rules{Prefixes: []string{"keep/"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := config
+	filtered.Filter = keys
+	plan := sync(filtered)
+	if len(plan.Upload) != 0 || plan.Unchanged() != 1 {
+		t.Errorf("filtered sync uploads %d and keeps %d unchanged, want 0 and 1", len(plan.Upload), plan.Unchanged())
+	}
+	if !reflect.DeepEqual(plan.Delete, []string{"keep/2"}) {
+		t.Errorf("filtered sync deletes %v, want [keep/2]", plan.Delete)
+	}
+
+	// Objects excluded by their size are not deleted either.
+	sized, err := filter.New(filter.Config{MinSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered.Filter = sized
+	if plan := sync(filtered); len(plan.Upload) != 0 || len(plan.Delete) != 0 {
+		t.Errorf("sync by size uploads %d and deletes %v, want nothing", len(plan.Upload), plan.Delete)
+	}
+
+	// The state still holds the objects outside the filters, which are not uploaded again.
+	if plan := sync(config); len(plan.Upload) != 0 || len(plan.Delete) != 0 || plan.Unchanged() != 4 {
+		t.Errorf("unfiltered sync uploads %d, deletes %v and keeps %d unchanged, want 0, none and 4", len(plan.Upload), plan.Delete, plan.Unchanged())
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package chunker
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Chunking strategies.
+const (
+	// None streams every object to a single Storj object under its original key.
+	None = "none"
+	// Fixed splits every object into chunks of the same size, uploaded as "<key>/<i>".
+	Fixed = "fixed"
+	// Content splits objects at content-defined boundaries found with a rolling hash.
+	// Chunks are stored once under their content hash and shared between snapshots.
+	Content = "content"
+)
+
+// DefaultChunkSize is the default size of fixed chunks, aligned to the Storj segment size.
+const DefaultChunkSize = 64 * 1024 * 1024
+
+// DefaultAverageChunkSize is the default average size of content-defined chunks.
+const DefaultAverageChunkSize = 4 * 1024 * 1024
+
+// MinAverageChunkSize is the smallest average size of content-defined chunks. Smaller chunks
+// would turn every few bytes of an object into an upload of its own.
+const MinAverageChunkSize = 64 * 1024
+
+// LegacyChunkSize is the chunk size used by runs that did not record their chunking. Their manifest
+// entries and checkpoints are read as fixed chunks of this size.
+const LegacyChunkSize = 32 * 1024
+
+// Config describes how objects are split into chunks.
+// For fixed chunking Size is the chunk size, for content-defined chunking the average chunk size.
+type Config struct {
+	Strategy string `json:"strategy"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// Validate checks that the strategy is known and the chunk size is usable.
+func (config Config) Validate() error {
+	switch config.Strategy {
+	case None:
+		return nil
+	case Fixed:
+		if config.Size <= 0 {
+			return fmt.Errorf("chunk size must be positive, got %d", config.Size)
+		}
+		return nil
+	case Content:
+		if config.Size < MinAverageChunkSize {
+			return fmt.Errorf("average chunk size must be at least %d, got %d", MinAverageChunkSize, config.Size)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown chunking strategy %q", config.Strategy)
+	}
+}
+
+// Count returns the number of chunks an object of the given size is split into.
+// It is not known in advance for content-defined chunking and returns 0 then.
+func (config Config) Count(objectSize int64) int {
+	switch config.Strategy {
+	case None:
+		return 1
+	case Content:
+		return 0
+	}
+	return int((objectSize + config.Size - 1) / config.Size)
+}
+
+// ParseSize parses a size such as "65536", "32KiB", "64MiB" or "1GB" into bytes.
+func ParseSize(size string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+		{"B", 1},
+	}
+	value := strings.TrimSpace(size)
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	if number > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size %q is too large", size)
+	}
+	return number * multiplier, nil
+}
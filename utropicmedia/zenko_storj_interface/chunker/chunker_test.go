@@ -0,0 +1,62 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package chunker
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for _, test := range []struct {
+		size string
+		want int64
+	}{
+		{"65536", 65536},
+		{"32KiB", 32 << 10},
+		{"64 MiB", 64 << 20},
+		{"1GB", 1000 * 1000 * 1000},
+		{"8589934591GiB", 8589934591 << 30},
+		{"9223372036854775807B", 9223372036854775807},
+	} {
+		got, err := ParseSize(test.size)
+		if err != nil {
+			t.Errorf("ParseSize(%q): %v", test.size, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", test.size, got, test.want)
+		}
+	}
+
+	for _, size := range []string{"", "abc", "-1", "1.5MiB", "8589934592GiB", "99999999999GiB", "9223372036854775808"} {
+		if got, err := ParseSize(size); err == nil {
+			t.Errorf("ParseSize(%q) = %d, want an error", size, got)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, config := range []Config{
+		{Strategy: None},
+		{Strategy: Fixed, Size: 1},
+		{Strategy: Fixed, Size: DefaultChunkSize},
+		{Strategy: Content, Size: MinAverageChunkSize},
+		{Strategy: Content, Size: DefaultAverageChunkSize},
+	} {
+		if err := config.Validate(); err != nil {
+			t.Errorf("%+v: %v", config, err)
+		}
+	}
+
+	for _, config := range []Config{
+		{Strategy: "rabin", Size: DefaultChunkSize},
+		{Strategy: Fixed},
+		{Strategy: Fixed, Size: -1},
+		{Strategy: Content},
+		{Strategy: Content, Size: 1},
+		{Strategy: Content, Size: MinAverageChunkSize - 1},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%+v: want an error", config)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package chunker
+
+import (
+	"bufio"
+	"io"
+)
+
+// gear holds the random values mixed into the rolling hash for every byte value.
+var gear = newGearTable()
+
+// newGearTable fills the gear table from a fixed seed, so that chunk boundaries
+// stay the same between runs and versions.
+func newGearTable() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x5a656e6b6f53746f) // "ZenkoSto"
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}
+
+// Splitter splits a stream into content-defined chunks using a gear rolling hash.
+// Chunks are between a quarter and four times the average size.
+type Splitter struct {
+	reader *bufio.Reader
+	mask   uint64
+	min    int
+	max    int
+}
+
+// NewSplitter returns a Splitter over reader with chunks of the given average size.
+func NewSplitter(reader io.Reader, averageSize int64) *Splitter {
+	bits := uint(0)
+	for int64(1)<<(bits+1) <= averageSize {
+		bits++
+	}
+	// Every chunk holds at least one byte, even for average sizes Config.Validate rejects.
+	min := int(averageSize / 4)
+	if min < 1 {
+		min = 1
+	}
+	max := int(averageSize * 4)
+	if max < min {
+		max = min
+	}
+	return &Splitter{
+		reader: bufio.NewReaderSize(reader, 1<<20),
+		mask:   (uint64(1) << bits) - 1,
+		min:    min,
+		max:    max,
+	}
+}
+
+// Next returns the next chunk. It returns io.EOF once the stream is exhausted.
+func (splitter *Splitter) Next() ([]byte, error) {
+	chunk := make([]byte, 0, splitter.min)
+	var hash uint64
+	for len(chunk) < splitter.max {
+		b, err := splitter.reader.ReadByte()
+		if err == io.EOF {
+			if len(chunk) == 0 {
+				return nil, io.EOF
+			}
+			return chunk, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunk = append(chunk, b)
+		hash = (hash << 1) + gear[b]
+		if len(chunk) >= splitter.min && hash&splitter.mask == 0 {
+			break
+		}
+	}
+	return chunk, nil
+}
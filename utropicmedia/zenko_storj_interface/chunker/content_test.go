@@ -0,0 +1,59 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package chunker
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestSplitter(t *testing.T) {
+	data := make([]byte, 2<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	splitter := NewSplitter(bytes.NewReader(data), MinAverageChunkSize)
+	var joined []byte
+	for {
+		chunk, err := splitter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) > 4*MinAverageChunkSize {
+			t.Errorf("chunk of %d bytes is larger than the maximum", len(chunk))
+		}
+		if len(joined)+len(chunk) < len(data) && len(chunk) < MinAverageChunkSize/4 {
+			t.Errorf("chunk of %d bytes is smaller than the minimum", len(chunk))
+		}
+		joined = append(joined, chunk...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("chunks do not add up to the data")
+	}
+}
+
+func TestSplitterTinyAverage(t *testing.T) {
+	splitter := NewSplitter(bytes.NewReader([]byte("abc")), 1)
+	var joined []byte
+	for {
+		chunk, err := splitter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) == 0 {
+			t.Fatal("empty chunk")
+		}
+		joined = append(joined, chunk...)
+	}
+	if string(joined) != "abc" {
+		t.Errorf("chunks add up to %q", joined)
+	}
+}
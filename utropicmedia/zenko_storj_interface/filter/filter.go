@@ -0,0 +1,435 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package filter selects the Zenko buckets and object keys a command works on.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules lists patterns matched against Zenko bucket names and object keys.
+type Rules struct {
+	// Buckets are bucket name patterns, e.g. "logs-*", matched with path.Match.
+	Buckets []string `json:"buckets,omitempty"`
+	// Prefixes are key prefixes, e.g. "photos/2019/".
+	Prefixes []string `json:"prefixes,omitempty"`
+	// Globs are patterns matched against the whole key, e.g. "**/*.jpg".
+	// "*" and "?" do not match "/", "**" matches any number of folders.
+	Globs []string `json:"globs,omitempty"`
+	// Regexes are regular expressions matched against the key.
+	Regexes []string `json:"regexes,omitempty"`
+}
+
+// Config holds the include and exclude rules. A bucket or key is selected when it
+// matches an include rule, or there is none of its kind, and matches no exclude rule.
+// Objects are further selected by their modification time and size.
+type Config struct {
+	Include Rules `json:"include"`
+	Exclude Rules `json:"exclude"`
+	// ModifiedAfter selects objects modified at or after the time.
+	ModifiedAfter *time.Time `json:"modifiedAfter,omitempty"`
+	// ModifiedBefore selects objects modified before the time.
+	ModifiedBefore *time.Time `json:"modifiedBefore,omitempty"`
+	// MinSize selects objects of at least MinSize bytes.
+	MinSize int64 `json:"minSize,omitempty"`
+	// MaxSize selects objects of at most MaxSize bytes, 0 for no limit.
+	MaxSize int64 `json:"maxSize,omitempty"`
+}
+
+// IsEmpty reports whether the configuration selects everything.
+func (config Config) IsEmpty() bool {
+	return config.Include.isEmpty() && config.Exclude.isEmpty() &&
+		config.ModifiedAfter == nil && config.ModifiedBefore == nil && config.MinSize == 0 && config.MaxSize == 0
+}
+
+// String describes the configuration in a single line.
+func (config Config) String() string {
+	var parts []string
+	describe := func(name string, values []string) {
+		if len(values) > 0 {
+			parts = append(parts, name+" "+strings.Join(values, ", "))
+		}
+	}
+	describe("buckets", config.Include.Buckets)
+	describe("prefixes", config.Include.Prefixes)
+	describe("globs", config.Include.Globs)
+	describe("regexes", config.Include.Regexes)
+	describe("excluding buckets", config.Exclude.Buckets)
+	describe("excluding prefixes", config.Exclude.Prefixes)
+	describe("excluding globs", config.Exclude.Globs)
+	describe("excluding regexes", config.Exclude.Regexes)
+	if config.ModifiedAfter != nil {
+		parts = append(parts, "modified after "+config.ModifiedAfter.Format(time.RFC3339))
+	}
+	if config.ModifiedBefore != nil {
+		parts = append(parts, "modified before "+config.ModifiedBefore.Format(time.RFC3339))
+	}
+	if config.MinSize > 0 {
+		parts = append(parts, "at least "+strconv.FormatInt(config.MinSize, 10)+" bytes")
+	}
+	if config.MaxSize > 0 {
+		parts = append(parts, "at most "+strconv.FormatInt(config.MaxSize, 10)+" bytes")
+	}
+	if len(parts) == 0 {
+		return "everything"
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (rules Rules) isEmpty() bool {
+	return len(rules.Buckets) == 0 && len(rules.Prefixes) == 0 && len(rules.Globs) == 0 && len(rules.Regexes) == 0
+}
+
+// Merge returns the configuration with the rules of other added to it.
+// The time and size limits of other replace those of the configuration when set.
+func (config Config) Merge(other Config) Config {
+	merged := Config{
+		Include:        config.Include.merge(other.Include),
+		Exclude:        config.Exclude.merge(other.Exclude),
+		ModifiedAfter:  config.ModifiedAfter,
+		ModifiedBefore: config.ModifiedBefore,
+		MinSize:        config.MinSize,
+		MaxSize:        config.MaxSize,
+	}
+	if other.ModifiedAfter != nil {
+		merged.ModifiedAfter = other.ModifiedAfter
+	}
+	if other.ModifiedBefore != nil {
+		merged.ModifiedBefore = other.ModifiedBefore
+	}
+	if other.MinSize != 0 {
+		merged.MinSize = other.MinSize
+	}
+	if other.MaxSize != 0 {
+		merged.MaxSize = other.MaxSize
+	}
+	return merged
+}
+
+func (rules Rules) merge(other Rules) Rules {
+	return Rules{
+		Buckets:  append(append([]string(nil), rules.Buckets...), other.Buckets...),
+		Prefixes: append(append([]string(nil), rules.Prefixes...), other.Prefixes...),
+		Globs:    append(append([]string(nil), rules.Globs...), other.Globs...),
+		Regexes:  append(append([]string(nil), rules.Regexes...), other.Regexes...),
+	}
+}
+
+// Filter is a compiled Config.
+type Filter struct {
+	includeBuckets  []string
+	excludeBuckets  []string
+	includePrefixes []string
+	excludePrefixes []string
+	includeKeys     []*regexp.Regexp
+	excludeKeys     []*regexp.Regexp
+	listPrefixes    []string
+
+	modifiedAfter  *time.Time
+	modifiedBefore *time.Time
+	minSize        int64
+	maxSize        int64
+}
+
+// New compiles the configuration. A nil *Filter selects everything.
+func New(config Config) (*Filter, error) {
+	filter := &Filter{
+		includeBuckets:  config.Include.Buckets,
+		excludeBuckets:  config.Exclude.Buckets,
+		includePrefixes: config.Include.Prefixes,
+		modifiedAfter:   config.ModifiedAfter,
+		modifiedBefore:  config.ModifiedBefore,
+		minSize:         config.MinSize,
+		maxSize:         config.MaxSize,
+	}
+	if config.MinSize < 0 || config.MaxSize < 0 {
+		return nil, fmt.Errorf("object sizes must not be negative")
+	}
+	if config.MaxSize > 0 && config.MinSize > config.MaxSize {
+		return nil, fmt.Errorf("minimum size %d is larger than maximum size %d", config.MinSize, config.MaxSize)
+	}
+	if config.ModifiedAfter != nil && config.ModifiedBefore != nil && !config.ModifiedAfter.Before(*config.ModifiedBefore) {
+		return nil, fmt.Errorf("modified after %s is not before modified before %s", config.ModifiedAfter.Format(time.RFC3339), config.ModifiedBefore.Format(time.RFC3339))
+	}
+	for _, prefix := range config.Exclude.Prefixes {
+		if prefix == "" {
+			return nil, fmt.Errorf("excluded prefixes must not be empty")
+		}
+		filter.excludePrefixes = append(filter.excludePrefixes, prefix)
+	}
+	for _, pattern := range append(append([]string(nil), config.Include.Buckets...), config.Exclude.Buckets...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid bucket pattern %q: %v", pattern, err)
+		}
+	}
+
+	// Keys are only listed below the prefixes an include rule can match.
+	listAll := false
+	listPrefixes := append([]string(nil), config.Include.Prefixes...)
+	for _, glob := range config.Include.Globs {
+		re, err := compileGlob(glob)
+		if err != nil {
+			return nil, err
+		}
+		filter.includeKeys = append(filter.includeKeys, re)
+		listPrefixes = append(listPrefixes, globPrefix(glob))
+	}
+	for _, expr := range config.Include.Regexes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", expr, err)
+		}
+		filter.includeKeys = append(filter.includeKeys, re)
+		prefix, anchored := regexPrefix(expr)
+		if !anchored {
+			listAll = true
+			continue
+		}
+		listPrefixes = append(listPrefixes, prefix)
+	}
+	if listAll || len(listPrefixes) == 0 {
+		listPrefixes = []string{""}
+	}
+	filter.listPrefixes = reducePrefixes(listPrefixes)
+
+	// Globs that exclude a whole folder are pruned like prefixes.
+	for _, glob := range config.Exclude.Globs {
+		if prefix := strings.TrimSuffix(glob, "**"); prefix != glob && prefix != "" && globPrefix(prefix) == prefix {
+			filter.excludePrefixes = append(filter.excludePrefixes, prefix)
+			continue
+		}
+		re, err := compileGlob(glob)
+		if err != nil {
+			return nil, err
+		}
+		filter.excludeKeys = append(filter.excludeKeys, re)
+	}
+	for _, expr := range config.Exclude.Regexes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", expr, err)
+		}
+		filter.excludeKeys = append(filter.excludeKeys, re)
+	}
+	return filter, nil
+}
+
+// Bucket reports whether the bucket is selected.
+func (filter *Filter) Bucket(name string) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.includeBuckets) > 0 && !matchBucket(filter.includeBuckets, name) {
+		return false
+	}
+	return !matchBucket(filter.excludeBuckets, name)
+}
+
+// Key reports whether the object key is selected.
+func (filter *Filter) Key(key string) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.ExcludedPrefix(key) != "" {
+		return false
+	}
+	for _, re := range filter.excludeKeys {
+		if re.MatchString(key) {
+			return false
+		}
+	}
+	if len(filter.includePrefixes) == 0 && len(filter.includeKeys) == 0 {
+		return true
+	}
+	for _, prefix := range filter.includePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	for _, re := range filter.includeKeys {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Object reports whether the object with the given key, size and modification time is selected.
+func (filter *Filter) Object(key string, size int64, modified time.Time) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.modifiedAfter != nil && modified.Before(*filter.modifiedAfter) {
+		return false
+	}
+	if filter.modifiedBefore != nil && !modified.Before(*filter.modifiedBefore) {
+		return false
+	}
+	if size < filter.minSize || (filter.maxSize > 0 && size > filter.maxSize) {
+		return false
+	}
+	return filter.Key(key)
+}
+
+// Keys returns a filter with only the bucket and key rules of the filter, which selects
+// objects of any size and modification time.
+func (filter *Filter) Keys() *Filter {
+	if filter == nil {
+		return nil
+	}
+	keys := *filter
+	keys.modifiedAfter, keys.modifiedBefore = nil, nil
+	keys.minSize, keys.maxSize = 0, 0
+	return &keys
+}
+
+// ListPrefixes returns the prefixes that hold every selected key. None of them is below another.
+func (filter *Filter) ListPrefixes() []string {
+	if filter == nil {
+		return []string{""}
+	}
+	return filter.listPrefixes
+}
+
+// ExcludedPrefix returns the excluded prefix the key is below, or "" when there is none.
+// Listings skip everything below the returned prefix.
+func (filter *Filter) ExcludedPrefix(key string) string {
+	if filter == nil {
+		return ""
+	}
+	for _, prefix := range filter.excludePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func matchBucket(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob translates a glob into an anchored regular expression.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				// "**/" also matches no folder at all.
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					expr.WriteString("(.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob %q: unterminated [", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %v", glob, err)
+	}
+	return re, nil
+}
+
+// globPrefix returns the literal part of a glob before its first wildcard.
+func globPrefix(glob string) string {
+	if i := strings.IndexAny(glob, "*?[\\"); i >= 0 {
+		return glob[:i]
+	}
+	return glob
+}
+
+// ParseTime parses a point in time given as an RFC 3339 time, a date (2006-01-02)
+// or an age before now such as 36h or 30d.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use 2006-01-02, 2006-01-02T15:04:05Z07:00 or an age such as 36h or 30d", value)
+}
+
+// regexPrefix returns the literal text every match of a regular expression anchored
+// at the start of the key begins with. It reports false for unanchored expressions.
+func regexPrefix(expr string) (string, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	if len(subs) == 0 || subs[0].Op != syntax.OpBeginText {
+		return "", false
+	}
+	if len(subs) > 1 && subs[1].Op == syntax.OpLiteral && subs[1].Flags&syntax.FoldCase == 0 {
+		return string(subs[1].Rune), true
+	}
+	return "", true
+}
+
+// reducePrefixes sorts the prefixes and removes those below another one.
+func reducePrefixes(prefixes []string) []string {
+	sorted := append([]string(nil), prefixes...)
+	sort.Strings(sorted)
+	var reduced []string
+	for _, prefix := range sorted {
+		if len(reduced) > 0 && strings.HasPrefix(prefix, reduced[len(reduced)-1]) {
+			continue
+		}
+		reduced = append(reduced, prefix)
+	}
+	return reduced
+}
@@ -0,0 +1,238 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package filter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilterKeys(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		config   Config
+		list     []string
+		selected []string
+		rejected []string
+		// pruned maps keys to the excluded prefix listings skip them with.
+		pruned map[string]string
+	}{
+		{
+			name:     "everything",
+			list:     []string{""},
+			selected: []string{"a", "a/b/c.jpg"},
+		},
+		{
+			name:     "double star in any folder",
+			config:   Config{Include: Rules{Globs: []string{"**/*.jpg"}}},
+			list:     []string{""},
+			selected: []string{"a.jpg", "x/a.jpg", "x/y/z/a.jpg"},
+			rejected: []string{"a.jpg.txt", "x/a.png", "a.jpgx"},
+		},
+		{
+			name:     "double star below a folder",
+			config:   Config{Include: Rules{Globs: []string{"photos/**/*.jpg"}}},
+			list:     []string{"photos/"},
+			selected: []string{"photos/a.jpg", "photos/2019/01/a.jpg"},
+			rejected: []string{"videos/a.jpg", "photos/a.png", "photos.jpg"},
+		},
+		{
+			name:     "trailing double star",
+			config:   Config{Include: Rules{Globs: []string{"logs/**"}}},
+			list:     []string{"logs/"},
+			selected: []string{"logs/a", "logs/2020/01/a.gz"},
+			rejected: []string{"logs", "other/logs/a"},
+		},
+		{
+			name:     "single star and question mark stay in their folder",
+			config:   Config{Include: Rules{Globs: []string{"logs/202?/*.gz"}}},
+			list:     []string{"logs/202"},
+			selected: []string{"logs/2020/a.gz", "logs/2021/.gz"},
+			rejected: []string{"logs/20201/a.gz", "logs/2020/sub/a.gz", "logs/202/a.gz", "logs/2020/a.gzip"},
+		},
+		{
+			name:     "character classes",
+			config:   Config{Include: Rules{Globs: []string{"data/[a-c][!0-9].csv"}}},
+			list:     []string{"data/"},
+			selected: []string{"data/ax.csv", "data/c_.csv"},
+			rejected: []string{"data/dx.csv", "data/a1.csv", "data/a.csv"},
+		},
+		{
+			name:     "escaped wildcard",
+			config:   Config{Include: Rules{Globs: []string{`notes\*.txt`}}},
+			list:     []string{"notes"},
+			selected: []string{"notes*.txt"},
+			rejected: []string{"notes1.txt", `notes\1.txt`},
+		},
+		{
+			name:     "anchored regex",
+			config:   Config{Include: Rules{Regexes: []string{`^backups/[0-9]+/`}}},
+			list:     []string{"backups/"},
+			selected: []string{"backups/12/a", "backups/1/"},
+			rejected: []string{"backups/x/a", "old/backups/12/a"},
+		},
+		{
+			name:     "anchored regex ignoring case",
+			config:   Config{Include: Rules{Regexes: []string{`(?i)^photos/`}}},
+			list:     []string{""},
+			selected: []string{"photos/a", "PHOTOS/a"},
+			rejected: []string{"videos/a"},
+		},
+		{
+			name: "unanchored regex lists everything",
+			config: Config{Include: Rules{
+				Prefixes: []string{"photos/"},
+				Regexes:  []string{`\.tmp$`},
+			}},
+			list:     []string{""},
+			selected: []string{"photos/a.jpg", "a/b.tmp"},
+			rejected: []string{"a/b.tmp.gz"},
+		},
+		{
+			name: "nested include prefixes",
+			config: Config{Include: Rules{
+				Prefixes: []string{"a/b/", "a/"},
+				Globs:    []string{"c/*.x", "a/b/**"},
+			}},
+			list:     []string{"a/", "c/"},
+			selected: []string{"a/z", "c/y.x"},
+			rejected: []string{"b/a", "c/d/y.x"},
+		},
+		{
+			name: "exclude prunes a whole prefix",
+			config: Config{Exclude: Rules{
+				Prefixes: []string{"tmp/"},
+				Globs:    []string{"cache/**", "**/*.bak"},
+			}},
+			list:     []string{""},
+			selected: []string{"cached", "cache", "a/b.bak.txt"},
+			rejected: []string{"tmp/a", "cache/a", "cache/x/y", "a.bak", "a/b.bak"},
+			pruned:   map[string]string{"tmp/a": "tmp/", "cache/x/y": "cache/", "a/b.bak": "", "cached": ""},
+		},
+		{
+			name: "exclude glob with a wildcard folder is not pruned",
+			config: Config{Exclude: Rules{
+				Globs: []string{"*/cache/**"},
+			}},
+			list:     []string{""},
+			selected: []string{"cache/a", "a/b/cache/c"},
+			rejected: []string{"a/cache/b"},
+			pruned:   map[string]string{"a/cache/b": ""},
+		},
+		{
+			name: "include and exclude overlap",
+			config: Config{
+				Include: Rules{Prefixes: []string{"photos/"}, Globs: []string{"**/*.jpg"}},
+				Exclude: Rules{Globs: []string{"photos/private/**"}, Regexes: []string{`\.raw$`}},
+			},
+			list:     []string{""},
+			selected: []string{"photos/a.png", "videos/a.jpg", "photos/private.jpg"},
+			rejected: []string{"photos/private/a.jpg", "photos/a.raw", "videos/a.mp4"},
+			pruned:   map[string]string{"photos/private/a.jpg": "photos/private/", "photos/a.raw": ""},
+		},
+	} {
+		filter, err := New(test.config)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := filter.ListPrefixes(); !reflect.DeepEqual(got, test.list) {
+			t.Errorf("%s: lists %q, want %q", test.name, got, test.list)
+		}
+		for _, key := range test.selected {
+			if !filter.Key(key) {
+				t.Errorf("%s: %q is not selected", test.name, key)
+			}
+		}
+		for _, key := range test.rejected {
+			if filter.Key(key) {
+				t.Errorf("%s: %q is selected", test.name, key)
+			}
+		}
+		for key, prefix := range test.pruned {
+			if got := filter.ExcludedPrefix(key); got != prefix {
+				t.Errorf("%s: %q is below excluded prefix %q, want %q", test.name, key, got, prefix)
+			}
+		}
+	}
+}
+
+func TestFilterBuckets(t *testing.T) {
+	filter, err := New(Config{Include: Rules{Buckets: []string{"logs-*", "photos"}}, Exclude: Rules{Buckets: []string{"logs-old*"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"logs-2020":   true,
+		"photos":      true,
+		"logs-old":    false,
+		"logs-old-02": false,
+		"photos-2":    false,
+		"videos":      false,
+	} {
+		if got := filter.Bucket(name); got != want {
+			t.Errorf("Bucket(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFilterObjectAndKeys(t *testing.T) {
+	after := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter, err := New(Config{
+		Include:       Rules{Prefixes: []string{"a/"}},
+		ModifiedAfter: &after,
+		MinSize:       10,
+		MaxSize:       100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		key      string
+		size     int64
+		modified time.Time
+		object   bool
+		keys     bool
+	}{
+		{"a/x", 50, after, true, true},
+		{"a/x", 5, after, false, true},
+		{"a/x", 500, after, false, true},
+		{"a/x", 50, after.Add(-time.Second), false, true},
+		{"b/x", 50, after, false, false},
+	} {
+		if got := filter.Object(test.key, test.size, test.modified); got != test.object {
+			t.Errorf("Object(%q, %d, %s) = %v, want %v", test.key, test.size, test.modified, got, test.object)
+		}
+		if got := filter.Keys().Object(test.key, test.size, test.modified); got != test.keys {
+			t.Errorf("Keys().Object(%q, %d, %s) = %v, want %v", test.key, test.size, test.modified, got, test.keys)
+		}
+	}
+	// Keys leaves the filter it was made from alone.
+	if filter.Object("a/x", 5, after) {
+		t.Error("Keys removed the size limits of the filter")
+	}
+
+	var none *Filter
+	if none.Keys() != nil || !none.Key("a") || !none.Object("a", 1, after) || !reflect.DeepEqual(none.ListPrefixes(), []string{""}) {
+		t.Error("a nil filter does not select everything")
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	after := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, config := range map[string]Config{
+		"unterminated class": {Include: Rules{Globs: []string{"a/[bc"}}},
+		"invalid regex":      {Exclude: Rules{Regexes: []string{"a("}}},
+		"invalid bucket":     {Include: Rules{Buckets: []string{"logs-["}}},
+		"empty prefix":       {Exclude: Rules{Prefixes: []string{""}}},
+		"negative size":      {MinSize: -1},
+		"sizes swapped":      {MinSize: 10, MaxSize: 5},
+		"times swapped":      {ModifiedAfter: &after, ModifiedBefore: &after},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("%s: New accepted %+v", name, config)
+		}
+	}
+}
@@ -7,5 +7,6 @@ require (
 	github.com/minio/minio-go v6.0.14+incompatible
 	github.com/smartystreets/goconvey v1.6.4 // indirect
 	gopkg.in/ini.v1 v1.55.0 // indirect
+	storj.io/common v0.0.0-20200310192634-b730fe7e2fd5
 	storj.io/storj v0.35.2
 )
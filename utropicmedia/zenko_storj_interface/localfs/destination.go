@@ -0,0 +1,138 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package localfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+// Destination stores objects as the files below Root, the path of an object being the
+// path of its file relative to Root. It implements storj.Destination. Only the permissions
+// and the modification time recorded by Stat are kept of the metadata of an object.
+type Destination struct {
+	Root string
+}
+
+var _ storj.Destination = (*Destination)(nil)
+
+// Put implements storj.Destination. The file is written to a temporary file first and
+// renamed once it is complete.
+func (destination *Destination) Put(ctx context.Context, path string, data io.Reader, size int64, metadata storj.ObjectMetadata) error {
+	fileName, err := storj.RestorePath(destination.Root, path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	_, err = io.Copy(file, data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if mode, modTime, ok := metadata.FileAttributes(); ok {
+		if err := os.Chmod(file.Name(), mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(file.Name(), modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return os.Rename(file.Name(), fileName)
+}
+
+// List implements storj.Destination.
+func (destination *Destination) List(ctx context.Context, prefix string, recursive bool) ([]storj.ObjectInfo, error) {
+	var objects []storj.ObjectInfo
+	folders := make(map[string]bool)
+	start := filepath.Join(destination.Root, filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+	err := filepath.Walk(start, func(fileName string, info os.FileInfo, err error) error {
+		if err != nil {
+			if fileName == start && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(destination.Root, fileName)
+		if err != nil {
+			return err
+		}
+		path := filepath.ToSlash(rel)
+		if !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+		if i := strings.Index(path[len(prefix):], "/"); i >= 0 && !recursive {
+			folder := path[:len(prefix)+i+1]
+			if !folders[folder] {
+				folders[folder] = true
+				objects = append(objects, storj.ObjectInfo{Path: folder, IsPrefix: true})
+			}
+			return nil
+		}
+		objects = append(objects, storj.ObjectInfo{Path: path, Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return objects, nil
+}
+
+// Get implements storj.Destination. A missing file is reported as storj.ErrNotFound.
+func (destination *Destination) Get(ctx context.Context, path string, offset int64) (storj.ObjectInfo, io.ReadCloser, error) {
+	if _, err := storj.RestorePath(destination.Root, path); err != nil {
+		return storj.ObjectInfo{}, nil, err
+	}
+	object, metadata, err := Stat(destination.Root, path)
+	if os.IsNotExist(err) {
+		return storj.ObjectInfo{}, nil, fmt.Errorf("%s: %w", path, storj.ErrNotFound)
+	}
+	if err != nil {
+		return storj.ObjectInfo{}, nil, err
+	}
+	file, err := Open(destination.Root, path, offset)
+	if err != nil {
+		return storj.ObjectInfo{}, nil, err
+	}
+	info := storj.ObjectInfo{
+		Path:     path,
+		Size:     object.Size,
+		Modified: object.LastModified,
+		Metadata: metadata,
+	}
+	return info, file, nil
+}
+
+// Delete implements storj.Destination.
+func (destination *Destination) Delete(ctx context.Context, path string) error {
+	fileName, err := storj.RestorePath(destination.Root, path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fileName)
+}
@@ -0,0 +1,210 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package localfs reads the files of a local directory tree, such as an NFS mount,
+// so that they are backed up to Storj like the objects of a Zenko bucket, and stores
+// objects as files in place of a Storj bucket.
+package localfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// ListFiles calls fn for every regular file below root selected by keys, in key order like an
+// S3 listing. The key of a file is its path relative to root with slashes. Like zenko.ListObjects
+// it only walks the folders of the included prefixes and skips excluded prefixes without reading
+// them. Symbolic links and other special files are skipped.
+func ListFiles(ctx context.Context, root string, keys *filter.Filter, fn func(minio.ObjectInfo) error) error {
+	if _, err := os.Stat(root); err != nil {
+		return err
+	}
+	for _, prefix := range keys.ListPrefixes() {
+		// Start at the deepest folder that holds every key of the prefix.
+		folder := prefix[:strings.LastIndex(prefix, "/")+1]
+		start := filepath.Join(root, filepath.FromSlash(folder))
+		err := walkKeys(start, func(fileName string, info os.FileInfo, err error) error {
+			if err != nil {
+				if fileName == start && os.IsNotExist(err) {
+					// No file has the prefix.
+					return nil
+				}
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, fileName)
+			if err != nil {
+				return err
+			}
+			key := filepath.ToSlash(rel)
+			if info.IsDir() {
+				if fileName != start && keys.ExcludedPrefix(key+"/") != "" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.Mode().IsRegular() {
+				fmt.Println("Skipping special file :", fileName)
+				return nil
+			}
+			if !strings.HasPrefix(key, prefix) || !keys.Object(key, info.Size(), info.ModTime()) {
+				return nil
+			}
+			return fn(objectInfo(key, info))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkKeys walks the tree below root like filepath.Walk, but in the byte order of the keys of the
+// files. filepath.Walk sorts every folder by name, which lists "a/b" before "a.txt"; here the name
+// of a folder is compared with a slash appended, as it is in the keys below it.
+func walkKeys(root string, fn filepath.WalkFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(root, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walk calls fn for fileName and, when it is a folder, for everything below it in key order.
+func walk(fileName string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(fileName, info, nil)
+	}
+	entries, err := ioutil.ReadDir(fileName)
+	walkErr := fn(fileName, info, err)
+	if err != nil || walkErr != nil {
+		return walkErr
+	}
+	sort.Slice(entries, func(i, j int) bool { return keyName(entries[i]) < keyName(entries[j]) })
+	for _, entry := range entries {
+		err := walk(filepath.Join(fileName, entry.Name()), entry, fn)
+		if err != nil && (err != filepath.SkipDir || !entry.IsDir()) {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyName returns the name of a folder entry as it appears in the keys below the folder.
+func keyName(info os.FileInfo) string {
+	if info.IsDir() {
+		return info.Name() + "/"
+	}
+	return info.Name()
+}
+
+// Stat returns the information and the metadata of the file with the given key below root.
+// The metadata records the relative path, the modification time and the permissions of the file,
+// which storj.RestoreToDir applies again.
+func Stat(root string, key string) (minio.ObjectInfo, storj.ObjectMetadata, error) {
+	info, err := os.Stat(fileName(root, key))
+	if err != nil {
+		return minio.ObjectInfo{}, storj.ObjectMetadata{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return minio.ObjectInfo{}, storj.ObjectMetadata{}, fmt.Errorf("%s is not a regular file", key)
+	}
+	object := objectInfo(key, info)
+	metadata := storj.ObjectMetadata{
+		ContentType: object.ContentType,
+		UserMetadata: map[string]string{
+			storj.FilePathMetadata:    key,
+			storj.FileModTimeMetadata: info.ModTime().UTC().Format(time.RFC3339Nano),
+			storj.FileModeMetadata:    fmt.Sprintf("%04o", info.Mode().Perm()),
+		},
+	}
+	return object, metadata, nil
+}
+
+// Open returns the content of the file with the given key below root, starting at offset.
+func Open(root string, key string, offset int64) (io.ReadCloser, error) {
+	file, err := os.Open(fileName(root, key))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// Source is the backup.Source of the files below Root. Every bucket name refers to Root itself.
+type Source struct {
+	Root string
+}
+
+// List calls fn for every file below Root selected by keys, as ListFiles does.
+func (source *Source) List(ctx context.Context, bucket string, keys *filter.Filter, fn func(zenko.ObjectVersion) error) error {
+	return ListFiles(ctx, source.Root, keys, func(object minio.ObjectInfo) error {
+		return fn(zenko.ObjectVersion{ObjectInfo: object})
+	})
+}
+
+// Stat returns the metadata of a file, as Stat does.
+func (source *Source) Stat(ctx context.Context, bucket string, object zenko.ObjectVersion) (storj.ObjectMetadata, error) {
+	_, metadata, err := Stat(source.Root, object.Key)
+	return metadata, err
+}
+
+// Open returns the content of a file starting at offset.
+func (source *Source) Open(ctx context.Context, bucket string, object zenko.ObjectVersion, offset int64) (io.ReadCloser, error) {
+	return Open(source.Root, object.Key, offset)
+}
+
+// ETag returns the entity tag of a file. Reading every file to hash it would double the
+// work of a backup, so like most web servers it is derived from the modification time and
+// the size, which is enough to tell whether a file changed since the previous run.
+func ETag(info os.FileInfo) string {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(info.Size(), 16)
+}
+
+// objectInfo describes a file like the listing of a Zenko object.
+func objectInfo(key string, info os.FileInfo) minio.ObjectInfo {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return minio.ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		ETag:         ETag(info),
+		LastModified: info.ModTime(),
+		ContentType:  contentType,
+	}
+}
+
+// fileName returns the file the key refers to below root.
+func fileName(root string, key string) string {
+	return filepath.Join(root, filepath.FromSlash(key))
+}
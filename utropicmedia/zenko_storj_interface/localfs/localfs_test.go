@@ -0,0 +1,69 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package localfs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/localfs"
+)
+
+func TestListFilesKeyOrder(t *testing.T) {
+	root, err := ioutil.TempDir("", "localfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(root) }()
+	for _, key := range []string{"a/b", "a.txt", "a-b", "a0", "a/c/d", "a/c.e", "b/skip/x", "b/y"} {
+		fileName := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fileName, []byte(key), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		config filter.Config
+		want   []string
+	}{
+		{
+			// "-" < "." < "/" < "0", so the folder "a" is listed between "a.txt" and "a0".
+			want: []string{"a-b", "a.txt", "a/b", "a/c.e", "a/c/d", "a0", "b/skip/x", "b/y"},
+		},
+		{
+			config: filter.Config{Include: filter.Rules{Prefixes: []string{"a/"}}, Exclude: filter.Rules{Globs: []string{"a/c/**"}}},
+			want:   []string{"a/b", "a/c.e"},
+		},
+		{
+			config: filter.Config{Exclude: filter.Rules{Prefixes: []string{"b/skip/"}}},
+			want:   []string{"a-b", "a.txt", "a/b", "a/c.e", "a/c/d", "a0", "b/y"},
+		},
+	} {
+		keys, err := filter.New(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		err = localfs.ListFiles(context.Background(), root, keys, func(object minio.ObjectInfo) error {
+			got = append(got, object.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: listed %q, want %q", test.config, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package memory keeps objects in memory. Its Bucket takes the place of a Storj bucket
+// and its Source the place of the buckets of a Zenko instance, so that backups, restores
+// and migrations run without network access or credentials.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/storj"
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// Object is an object kept in memory.
+type Object struct {
+	Data     []byte
+	Metadata storj.ObjectMetadata
+	Modified time.Time
+	// ETag is the hex MD5 hash of Data, like the ETag of an S3 object uploaded in one part.
+	ETag string
+}
+
+// Bucket is an in-memory storj.Destination. It is safe for concurrent use.
+type Bucket struct {
+	mu      sync.Mutex
+	objects map[string]Object
+	fault   Fault
+}
+
+// Fault decides whether an operation on a Bucket fails, so that error paths can be exercised.
+// op is "put", "list", "get" or "delete"; path is the prefix for "list". A nil result
+// performs the operation.
+type Fault func(op string, path string) error
+
+var _ storj.Destination = (*Bucket)(nil)
+
+// NewBucket returns an empty Bucket.
+func NewBucket() *Bucket {
+	return &Bucket{objects: make(map[string]Object)}
+}
+
+// SetFault makes every following operation of the Destination methods pass through fault,
+// nil to perform every operation.
+func (bucket *Bucket) SetFault(fault Fault) {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	bucket.fault = fault
+}
+
+// Put implements storj.Destination.
+func (bucket *Bucket) Put(ctx context.Context, path string, data io.Reader, size int64, metadata storj.ObjectMetadata) error {
+	if err := bucket.check("put", path); err != nil {
+		return err
+	}
+	content, err := ioutil.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && int64(len(content)) != size {
+		return fmt.Errorf("%s: read %d bytes, expected %d", path, len(content), size)
+	}
+	bucket.PutObject(path, content, metadata)
+	return nil
+}
+
+// PutObject stores data at path with the given metadata.
+func (bucket *Bucket) PutObject(path string, data []byte, metadata storj.ObjectMetadata) {
+	sum := md5.Sum(data)
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	bucket.objects[path] = Object{
+		Data:     data,
+		Metadata: metadata,
+		Modified: time.Now(),
+		ETag:     hex.EncodeToString(sum[:]),
+	}
+}
+
+// List implements storj.Destination.
+func (bucket *Bucket) List(ctx context.Context, prefix string, recursive bool) ([]storj.ObjectInfo, error) {
+	if err := bucket.check("list", prefix); err != nil {
+		return nil, err
+	}
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	var objects []storj.ObjectInfo
+	folders := make(map[string]bool)
+	for path, object := range bucket.objects {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if i := strings.Index(path[len(prefix):], "/"); i >= 0 && !recursive {
+			folder := path[:len(prefix)+i+1]
+			if !folders[folder] {
+				folders[folder] = true
+				objects = append(objects, storj.ObjectInfo{Path: folder, IsPrefix: true})
+			}
+			continue
+		}
+		objects = append(objects, storj.ObjectInfo{Path: path, Size: int64(len(object.Data)), Modified: object.Modified})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return objects, nil
+}
+
+// Get implements storj.Destination. A missing object is reported as storj.ErrNotFound.
+func (bucket *Bucket) Get(ctx context.Context, path string, offset int64) (storj.ObjectInfo, io.ReadCloser, error) {
+	if err := bucket.check("get", path); err != nil {
+		return storj.ObjectInfo{}, nil, err
+	}
+	object, ok := bucket.Object(path)
+	if !ok {
+		return storj.ObjectInfo{}, nil, fmt.Errorf("%s: %w", path, storj.ErrNotFound)
+	}
+	if offset > int64(len(object.Data)) {
+		offset = int64(len(object.Data))
+	}
+	info := storj.ObjectInfo{
+		Path:     path,
+		Size:     int64(len(object.Data)),
+		Modified: object.Modified,
+		Metadata: object.Metadata,
+	}
+	return info, ioutil.NopCloser(bytes.NewReader(object.Data[offset:])), nil
+}
+
+// Delete implements storj.Destination.
+func (bucket *Bucket) Delete(ctx context.Context, path string) error {
+	if err := bucket.check("delete", path); err != nil {
+		return err
+	}
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	if _, ok := bucket.objects[path]; !ok {
+		return fmt.Errorf("%s: %w", path, storj.ErrNotFound)
+	}
+	delete(bucket.objects, path)
+	return nil
+}
+
+// check returns the error the fault, if any, fails an operation with.
+func (bucket *Bucket) check(op string, path string) error {
+	bucket.mu.Lock()
+	fault := bucket.fault
+	bucket.mu.Unlock()
+	if fault == nil {
+		return nil
+	}
+	return fault(op, path)
+}
+
+// Object returns the object stored at path.
+func (bucket *Bucket) Object(path string) (Object, bool) {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	object, ok := bucket.objects[path]
+	return object, ok
+}
+
+// Paths returns the paths of all objects, in order.
+func (bucket *Bucket) Paths() []string {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	paths := make([]string, 0, len(bucket.objects))
+	for path := range bucket.objects {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Source is an in-memory backup.Source whose buckets are Buckets, keyed by object key.
+// It is safe for concurrent use.
+type Source struct {
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+}
+
+// NewSource returns a Source without buckets.
+func NewSource() *Source {
+	return &Source{buckets: make(map[string]*Bucket)}
+}
+
+// Bucket returns the bucket with the given name, creating it when it does not exist.
+func (source *Source) Bucket(name string) *Bucket {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	bucket, ok := source.buckets[name]
+	if !ok {
+		bucket = NewBucket()
+		source.buckets[name] = bucket
+	}
+	return bucket
+}
+
+// Buckets returns the names of the buckets, in order.
+func (source *Source) Buckets() []string {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	names := make([]string, 0, len(source.buckets))
+	for name := range source.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List calls fn for every object of the bucket selected by keys, in key order.
+func (source *Source) List(ctx context.Context, bucket string, keys *filter.Filter, fn func(zenko.ObjectVersion) error) error {
+	objects := source.Bucket(bucket)
+	for _, key := range objects.Paths() {
+		object, ok := objects.Object(key)
+		if !ok || !keys.Object(key, int64(len(object.Data)), object.Modified) {
+			continue
+		}
+		err := fn(zenko.ObjectVersion{ObjectInfo: minio.ObjectInfo{
+			Key:          key,
+			Size:         int64(len(object.Data)),
+			ETag:         object.ETag,
+			LastModified: object.Modified,
+			ContentType:  object.Metadata.ContentType,
+		}})
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat returns the metadata of an object.
+func (source *Source) Stat(ctx context.Context, bucket string, object zenko.ObjectVersion) (storj.ObjectMetadata, error) {
+	stored, ok := source.Bucket(bucket).Object(object.Key)
+	if !ok {
+		return storj.ObjectMetadata{}, fmt.Errorf("%s: %w", storj.ObjectID(bucket, object.Key, ""), storj.ErrNotFound)
+	}
+	return stored.Metadata, nil
+}
+
+// Open returns the data of an object starting at offset.
+func (source *Source) Open(ctx context.Context, bucket string, object zenko.ObjectVersion, offset int64) (io.ReadCloser, error) {
+	_, reader, err := source.Bucket(bucket).Get(ctx, object.Key, offset)
+	return reader, err
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go"
+)
+
+// Default values of a retry policy.
+const (
+	DefaultAttempts   = 5
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+	DefaultJitter     = 0.2
+)
+
+// Policy retries failed Zenko and Storj calls with exponential backoff.
+// A nil *Policy runs every call exactly once.
+type Policy struct {
+	// Attempts is the maximum number of times a call is made.
+	Attempts int
+	// Backoff is the wait before the first retry; it doubles with every retry.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between two attempts.
+	MaxBackoff time.Duration
+	// Jitter randomizes every wait by up to this fraction, in both directions.
+	Jitter float64
+
+	retries int64
+}
+
+// New returns a retry policy.
+func New(attempts int, backoff time.Duration, maxBackoff time.Duration, jitter float64) *Policy {
+	return &Policy{
+		Attempts:   attempts,
+		Backoff:    backoff,
+		MaxBackoff: maxBackoff,
+		Jitter:     jitter,
+	}
+}
+
+// Retries returns the number of retries made with the policy so far.
+func (policy *Policy) Retries() int64 {
+	if policy == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&policy.retries)
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable,
+// the attempts are exhausted or ctx is canceled. Every retry is logged and counted.
+func (policy *Policy) Do(ctx context.Context, operation string, fn func() error) error {
+	if policy == nil || policy.Attempts <= 1 {
+		return fn()
+	}
+
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.Attempts || !IsRetryable(err) {
+			return err
+		}
+
+		wait := backoff
+		if policy.Jitter > 0 {
+			wait += time.Duration((rand.Float64()*2 - 1) * policy.Jitter * float64(backoff))
+		}
+		atomic.AddInt64(&policy.retries, 1)
+		fmt.Printf("Retrying %s (attempt %d of %d) in %s: %v\n", operation, attempt+1, policy.Attempts, wait.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// permanentCodes are S3 error codes that do not go away by retrying.
+var permanentCodes = map[string]bool{
+	"AccessDenied":          true,
+	"InvalidAccessKeyId":    true,
+	"SignatureDoesNotMatch": true,
+	"NoSuchBucket":          true,
+	"NoSuchKey":             true,
+	"InvalidBucketName":     true,
+	"InvalidObjectName":     true,
+	"InvalidArgument":       true,
+	"MethodNotAllowed":      true,
+	"NotImplemented":        true,
+}
+
+// permanentMessages are fragments of Storj error messages that do not go away by retrying.
+var permanentMessages = []string{
+	"object not found",
+	"bucket not found",
+	"permission denied",
+	"unauthorized",
+	"invalid api key",
+}
+
+// IsRetryable reports whether err may be transient: network failures, timeouts,
+// throttling and server errors are retried, while missing objects, denied access
+// and invalid requests are not.
+func IsRetryable(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return false
+	}
+
+	if response := minio.ToErrorResponse(err); response.Code != "" || response.StatusCode != 0 {
+		switch {
+		case response.StatusCode == http.StatusRequestTimeout, response.StatusCode == http.StatusTooManyRequests:
+			return true
+		case response.StatusCode >= 500:
+			return true
+		case permanentCodes[response.Code]:
+			return false
+		case response.StatusCode >= 400:
+			return false
+		}
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	if strings.Contains(message, "context canceled") {
+		return false
+	}
+	for _, permanent := range permanentMessages {
+		if strings.Contains(message, permanent) {
+			return false
+		}
+	}
+	return true
+}
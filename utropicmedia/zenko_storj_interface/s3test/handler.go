@@ -0,0 +1,713 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listLimit is the largest number of entries a listing returns.
+const listLimit = 1000
+
+// streamingPayload is the X-Amz-Content-Sha256 of request bodies signed chunk by chunk.
+const streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+// timeFormat is the format of times in listings.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+type errorResult struct {
+	XMLName    xml.Name `xml:"Error"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	BucketName string   `xml:"BucketName,omitempty"`
+	Key        string   `xml:"Key,omitempty"`
+	RequestID  string   `xml:"RequestId"`
+}
+
+type bucketEntry struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name      `xml:"ListAllMyBucketsResult"`
+	OwnerID string        `xml:"Owner>ID"`
+	Buckets []bucketEntry `xml:"Buckets>Bucket"`
+}
+
+type objectEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listBucketResult is the result of both ListObjects and ListObjectsV2; the fields
+// of the other version are left empty.
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Marker                string         `xml:"Marker,omitempty"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	KeyCount              int            `xml:"KeyCount,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectEntry  `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// versionEntry is a Version or a DeleteMarker element, as named by XMLName.
+type versionEntry struct {
+	XMLName      xml.Name
+	Key          string `xml:"Key"`
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         int64  `xml:"Size,omitempty"`
+}
+
+type listVersionsResult struct {
+	XMLName             xml.Name `xml:"ListVersionsResult"`
+	Name                string   `xml:"Name"`
+	Prefix              string   `xml:"Prefix"`
+	KeyMarker           string   `xml:"KeyMarker"`
+	VersionIDMarker     string   `xml:"VersionIdMarker"`
+	NextKeyMarker       string   `xml:"NextKeyMarker,omitempty"`
+	NextVersionIDMarker string   `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int      `xml:"MaxKeys"`
+	IsTruncated         bool     `xml:"IsTruncated"`
+	Entries             []versionEntry
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Tags    []tag    `xml:"TagSet>Tag"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+	ETag    string   `xml:"ETag"`
+}
+
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+// serveHTTP dispatches a request by its method, its path and the subresource of its query.
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	bucketName, key := r.URL.Path, ""
+	bucketName = strings.TrimPrefix(bucketName, "/")
+	if i := strings.Index(bucketName, "/"); i >= 0 {
+		bucketName, key = bucketName[:i], bucketName[i+1:]
+	}
+	query := r.URL.Query()
+
+	server.mu.Lock()
+	fault := server.fault
+	server.mu.Unlock()
+	if fault != nil {
+		if err := fault(r, bucketName, key); err != nil {
+			s3Err, ok := err.(*Error)
+			if !ok {
+				s3Err = &Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: err.Error()}
+			}
+			writeError(w, r, s3Err, bucketName, key)
+			return
+		}
+	}
+
+	var err *Error
+	switch {
+	case bucketName == "":
+		err = server.listBuckets(w, r)
+	case key == "":
+		_, versions := query["versions"]
+		_, location := query["location"]
+		switch {
+		case r.Method == http.MethodHead:
+			err = server.headBucket(w, bucketName)
+		case r.Method == http.MethodPut:
+			err = server.createBucket(w, bucketName)
+		case r.Method == http.MethodDelete:
+			err = server.deleteBucket(w, bucketName)
+		case r.Method == http.MethodGet && location:
+			err = writeXML(w, http.StatusOK, locationConstraint{})
+		case r.Method == http.MethodGet && versions:
+			err = server.listVersions(w, r, bucketName)
+		case r.Method == http.MethodGet:
+			err = server.listObjects(w, r, bucketName)
+		default:
+			err = notImplemented(r)
+		}
+	default:
+		_, taggingQuery := query["tagging"]
+		_, uploads := query["uploads"]
+		uploadID := query.Get("uploadId")
+		switch {
+		case taggingQuery:
+			err = server.objectTagging(w, r, bucketName, key)
+		case r.Method == http.MethodPost && uploads:
+			err = server.createUpload(w, r, bucketName, key)
+		case r.Method == http.MethodPost && uploadID != "":
+			err = server.completeUpload(w, r, bucketName, key, uploadID)
+		case r.Method == http.MethodPut && uploadID != "":
+			err = server.uploadPart(w, r, uploadID)
+		case r.Method == http.MethodDelete && uploadID != "":
+			err = server.abortUpload(w, uploadID)
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			err = notImplemented(r)
+		case r.Method == http.MethodPut:
+			err = server.putObject(w, r, bucketName, key)
+		case r.Method == http.MethodGet, r.Method == http.MethodHead:
+			err = server.getObject(w, r, bucketName, key)
+		case r.Method == http.MethodDelete:
+			err = server.deleteObject(w, r, bucketName, key)
+		default:
+			err = notImplemented(r)
+		}
+	}
+	if err != nil {
+		writeError(w, r, err, bucketName, key)
+	}
+}
+
+func (server *Server) listBuckets(w http.ResponseWriter, r *http.Request) *Error {
+	if r.Method != http.MethodGet {
+		return notImplemented(r)
+	}
+	server.mu.Lock()
+	result := listAllMyBucketsResult{OwnerID: "s3test"}
+	for name, bucket := range server.buckets {
+		result.Buckets = append(result.Buckets, bucketEntry{Name: name, CreationDate: bucket.created.Format(timeFormat)})
+	}
+	server.mu.Unlock()
+	sort.Slice(result.Buckets, func(i, j int) bool { return result.Buckets[i].Name < result.Buckets[j].Name })
+	return writeXML(w, http.StatusOK, result)
+}
+
+func (server *Server) headBucket(w http.ResponseWriter, bucketName string) *Error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if _, ok := server.buckets[bucketName]; !ok {
+		return noSuchBucket()
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (server *Server) createBucket(w http.ResponseWriter, bucketName string) *Error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if _, ok := server.buckets[bucketName]; ok {
+		return &Error{Status: http.StatusConflict, Code: "BucketAlreadyOwnedByYou", Message: "Your previous request to create the named bucket succeeded and you already own it."}
+	}
+	server.makeBucket(bucketName)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (server *Server) deleteBucket(w http.ResponseWriter, bucketName string) *Error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		return noSuchBucket()
+	}
+	if len(bucket.keys) > 0 {
+		return &Error{Status: http.StatusConflict, Code: "BucketNotEmpty", Message: "The bucket you tried to delete is not empty."}
+	}
+	delete(server.buckets, bucketName)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// listObjects serves ListObjects and, with list-type=2, ListObjectsV2.
+func (server *Server) listObjects(w http.ResponseWriter, r *http.Request, bucketName string) *Error {
+	query := r.URL.Query()
+	result := listBucketResult{
+		Name:      bucketName,
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+		MaxKeys:   maxKeys(query.Get("max-keys")),
+	}
+	v2 := query.Get("list-type") == "2"
+	after := query.Get("marker")
+	if v2 {
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = query.Get("start-after")
+		after = result.StartAfter
+		if result.ContinuationToken > after {
+			after = result.ContinuationToken
+		}
+	} else {
+		result.Marker = after
+	}
+
+	server.mu.Lock()
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		server.mu.Unlock()
+		return noSuchBucket()
+	}
+	var last string
+	for _, key := range bucket.sortedKeys() {
+		object := bucket.keys[key][0]
+		if key <= after || !strings.HasPrefix(key, result.Prefix) || object.DeleteMarker {
+			continue
+		}
+		if result.Delimiter != "" {
+			if i := strings.Index(key[len(result.Prefix):], result.Delimiter); i >= 0 {
+				prefix := key[:len(result.Prefix)+i+len(result.Delimiter)]
+				// The prefix was listed by this page or by an earlier one.
+				if prefix == last || strings.HasPrefix(after, prefix) {
+					continue
+				}
+				if len(result.Contents)+len(result.CommonPrefixes) == result.MaxKeys {
+					result.IsTruncated = true
+					break
+				}
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: prefix})
+				last = prefix
+				continue
+			}
+		}
+		if len(result.Contents)+len(result.CommonPrefixes) == result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.Contents = append(result.Contents, objectEntry{
+			Key:          key,
+			LastModified: object.Modified.Format(timeFormat),
+			ETag:         quote(object.ETag),
+			Size:         int64(len(object.Data)),
+			StorageClass: "STANDARD",
+		})
+		last = key
+	}
+	server.mu.Unlock()
+
+	if result.IsTruncated {
+		if v2 {
+			result.NextContinuationToken = last
+		} else if result.Delimiter != "" {
+			result.NextMarker = last
+		}
+	}
+	if v2 {
+		result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+	}
+	return writeXML(w, http.StatusOK, result)
+}
+
+// listVersions serves ListObjectVersions, newest version first within a key.
+func (server *Server) listVersions(w http.ResponseWriter, r *http.Request, bucketName string) *Error {
+	query := r.URL.Query()
+	result := listVersionsResult{
+		Name:            bucketName,
+		Prefix:          query.Get("prefix"),
+		KeyMarker:       query.Get("key-marker"),
+		VersionIDMarker: query.Get("version-id-marker"),
+		MaxKeys:         maxKeys(query.Get("max-keys")),
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		return noSuchBucket()
+	}
+	for _, key := range bucket.sortedKeys() {
+		if key < result.KeyMarker || !strings.HasPrefix(key, result.Prefix) {
+			continue
+		}
+		versions := bucket.keys[key]
+		if key == result.KeyMarker {
+			// Continue after the marker version, or with the next key without one.
+			next := len(versions)
+			for i, object := range versions {
+				if result.VersionIDMarker != "" && object.VersionID == result.VersionIDMarker {
+					next = i + 1
+				}
+			}
+			versions = versions[next:]
+		}
+		for _, object := range versions {
+			if len(result.Entries) == result.MaxKeys {
+				result.IsTruncated = true
+				last := result.Entries[len(result.Entries)-1]
+				result.NextKeyMarker, result.NextVersionIDMarker = last.Key, last.VersionID
+				return writeXML(w, http.StatusOK, result)
+			}
+			entry := versionEntry{
+				XMLName:      xml.Name{Local: "Version"},
+				Key:          key,
+				VersionID:    object.VersionID,
+				IsLatest:     object == bucket.keys[key][0],
+				LastModified: object.Modified.Format(timeFormat),
+			}
+			if object.DeleteMarker {
+				entry.XMLName.Local = "DeleteMarker"
+			} else {
+				entry.ETag = quote(object.ETag)
+				entry.Size = int64(len(object.Data))
+			}
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return writeXML(w, http.StatusOK, result)
+}
+
+func (server *Server) putObject(w http.ResponseWriter, r *http.Request, bucketName string, key string) *Error {
+	data, err := readPayload(r)
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(data)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		return noSuchBucket()
+	}
+	object := server.put(bucket, key, data, objectHeader(r.Header), hex.EncodeToString(sum[:]))
+	w.Header().Set("ETag", quote(object.ETag))
+	if bucket.versioned {
+		w.Header().Set("X-Amz-Version-Id", object.VersionID)
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// getObject serves GetObject and HeadObject, with ranges and conditions.
+func (server *Server) getObject(w http.ResponseWriter, r *http.Request, bucketName string, key string) *Error {
+	server.mu.Lock()
+	object, err := server.lookup(bucketName, key, r.URL.Query().Get("versionId"))
+	server.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if object.DeleteMarker {
+		w.Header().Set("X-Amz-Delete-Marker", "true")
+		return &Error{Status: http.StatusMethodNotAllowed, Code: "MethodNotAllowed", Message: "The specified method is not allowed against this resource."}
+	}
+
+	for name, values := range object.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("ETag", quote(object.ETag))
+	if object.VersionID != NullVersion {
+		w.Header().Set("X-Amz-Version-Id", object.VersionID)
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, "", object.Modified, bytes.NewReader(object.Data))
+	return nil
+}
+
+func (server *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucketName string, key string) *Error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		return noSuchBucket()
+	}
+	if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+		server.dropVersion(bucket, key, versionID)
+	} else {
+		server.remove(bucket, key)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// objectTagging serves GetObjectTagging, PutObjectTagging and DeleteObjectTagging.
+func (server *Server) objectTagging(w http.ResponseWriter, r *http.Request, bucketName string, key string) *Error {
+	var document tagging
+	if r.Method == http.MethodPut {
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return &Error{Status: http.StatusBadRequest, Code: "IncompleteBody", Message: err.Error()}
+		}
+		if err := xml.Unmarshal(data, &document); err != nil {
+			return &Error{Status: http.StatusBadRequest, Code: "MalformedXML", Message: err.Error()}
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	object, err := server.lookup(bucketName, key, r.URL.Query().Get("versionId"))
+	if err != nil {
+		return err
+	}
+	switch r.Method {
+	case http.MethodGet:
+		for name, value := range object.Tags {
+			document.Tags = append(document.Tags, tag{Key: name, Value: value})
+		}
+		sort.Slice(document.Tags, func(i, j int) bool { return document.Tags[i].Key < document.Tags[j].Key })
+		return writeXML(w, http.StatusOK, document)
+	case http.MethodPut:
+		object.Tags = make(map[string]string, len(document.Tags))
+		for _, tag := range document.Tags {
+			object.Tags[tag.Key] = tag.Value
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		object.Tags = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return notImplemented(r)
+	}
+	return nil
+}
+
+func (server *Server) createUpload(w http.ResponseWriter, r *http.Request, bucketName string, key string) *Error {
+	server.mu.Lock()
+	if _, ok := server.buckets[bucketName]; !ok {
+		server.mu.Unlock()
+		return noSuchBucket()
+	}
+	server.nextID++
+	uploadID := fmt.Sprintf("upload-%016x", server.nextID)
+	server.uploads[uploadID] = &upload{
+		bucket: bucketName,
+		key:    key,
+		header: objectHeader(r.Header),
+		parts:  make(map[int][]byte),
+	}
+	server.mu.Unlock()
+	return writeXML(w, http.StatusOK, initiateMultipartUploadResult{Bucket: bucketName, Key: key, UploadID: uploadID})
+}
+
+func (server *Server) uploadPart(w http.ResponseWriter, r *http.Request, uploadID string) *Error {
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || number < 1 {
+		return &Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "Part number must be a positive integer."}
+	}
+	data, s3Err := readPayload(r)
+	if s3Err != nil {
+		return s3Err
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	upload, ok := server.uploads[uploadID]
+	if !ok {
+		return noSuchUpload()
+	}
+	upload.parts[number] = data
+	sum := md5.Sum(data)
+	w.Header().Set("ETag", quote(hex.EncodeToString(sum[:])))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (server *Server) completeUpload(w http.ResponseWriter, r *http.Request, bucketName string, key string, uploadID string) *Error {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return &Error{Status: http.StatusBadRequest, Code: "IncompleteBody", Message: err.Error()}
+	}
+	var document completeMultipartUpload
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return &Error{Status: http.StatusBadRequest, Code: "MalformedXML", Message: err.Error()}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	upload, ok := server.uploads[uploadID]
+	if !ok || upload.bucket != bucketName || upload.key != key {
+		return noSuchUpload()
+	}
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		return noSuchBucket()
+	}
+	var content, sums []byte
+	for _, part := range document.Parts {
+		partData, ok := upload.parts[part.PartNumber]
+		sum := md5.Sum(partData)
+		if !ok || strings.Trim(part.ETag, "\"") != hex.EncodeToString(sum[:]) {
+			return &Error{Status: http.StatusBadRequest, Code: "InvalidPart", Message: fmt.Sprintf("Part %d was not uploaded or its ETag does not match.", part.PartNumber)}
+		}
+		content = append(content, partData...)
+		sums = append(sums, sum[:]...)
+	}
+	delete(server.uploads, uploadID)
+
+	sum := md5.Sum(sums)
+	object := server.put(bucket, key, content, upload.header, hex.EncodeToString(sum[:])+"-"+strconv.Itoa(len(document.Parts)))
+	if bucket.versioned {
+		w.Header().Set("X-Amz-Version-Id", object.VersionID)
+	}
+	return writeXML(w, http.StatusOK, completeMultipartUploadResult{Bucket: bucketName, Key: key, ETag: quote(object.ETag)})
+}
+
+func (server *Server) abortUpload(w http.ResponseWriter, uploadID string) *Error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if _, ok := server.uploads[uploadID]; !ok {
+		return noSuchUpload()
+	}
+	delete(server.uploads, uploadID)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// lookup returns a version of a key, the current one when versionID is empty.
+func (server *Server) lookup(bucketName string, key string, versionID string) (*Object, *Error) {
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		return nil, noSuchBucket()
+	}
+	versions := bucket.keys[key]
+	if versionID == "" {
+		if len(versions) == 0 || versions[0].DeleteMarker {
+			return nil, &Error{Status: http.StatusNotFound, Code: "NoSuchKey", Message: "The specified key does not exist."}
+		}
+		return versions[0], nil
+	}
+	for _, object := range versions {
+		if object.VersionID == versionID {
+			return object, nil
+		}
+	}
+	return nil, &Error{Status: http.StatusNotFound, Code: "NoSuchVersion", Message: "The specified version does not exist."}
+}
+
+// readPayload returns the data of a PUT request. Bodies signed chunk by chunk, as the minio
+// client sends them over plain HTTP, are decoded; the chunk signatures are not checked.
+func readPayload(r *http.Request) ([]byte, *Error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Code: "IncompleteBody", Message: err.Error()}
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") != streamingPayload {
+		return data, nil
+	}
+
+	var payload []byte
+	for {
+		// Every chunk is "<hex size>;chunk-signature=<signature>\r\n<data>\r\n".
+		line := bytes.Index(data, []byte("\r\n"))
+		if line < 0 {
+			return nil, malformedChunk()
+		}
+		header := string(data[:line])
+		if i := strings.Index(header, ";"); i >= 0 {
+			header = header[:i]
+		}
+		size, err := strconv.ParseInt(header, 16, 64)
+		if err != nil || size < 0 || int64(len(data)-line-2) < size+2 {
+			return nil, malformedChunk()
+		}
+		data = data[line+2:]
+		if size == 0 {
+			return payload, nil
+		}
+		payload = append(payload, data[:size]...)
+		data = data[size+2:]
+	}
+}
+
+// writeXML sends an XML document.
+func writeXML(w http.ResponseWriter, status int, document interface{}) *Error {
+	data, err := xml.Marshal(document)
+	if err != nil {
+		return &Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(xml.Header)+len(data)))
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+	return nil
+}
+
+// writeError sends an S3 error. Responses to HEAD requests have no body, only the status.
+func writeError(w http.ResponseWriter, r *http.Request, err *Error, bucketName string, key string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(err.Status)
+		return
+	}
+	_ = writeXML(w, err.Status, errorResult{
+		Code:       err.Code,
+		Message:    err.Message,
+		BucketName: bucketName,
+		Key:        key,
+		RequestID:  strconv.FormatInt(time.Now().UnixNano(), 16),
+	})
+}
+
+func noSuchBucket() *Error {
+	return &Error{Status: http.StatusNotFound, Code: "NoSuchBucket", Message: "The specified bucket does not exist."}
+}
+
+func malformedChunk() *Error {
+	return &Error{Status: http.StatusBadRequest, Code: "IncompleteBody", Message: "The chunked request body is malformed."}
+}
+
+func noSuchUpload() *Error {
+	return &Error{Status: http.StatusNotFound, Code: "NoSuchUpload", Message: "The specified multipart upload does not exist."}
+}
+
+func notImplemented(r *http.Request) *Error {
+	return &Error{Status: http.StatusNotImplemented, Code: "NotImplemented", Message: r.Method + " " + r.URL.RawQuery + " is not implemented."}
+}
+
+// maxKeys returns the number of entries a listing returns for its max-keys parameter.
+func maxKeys(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 || n > listLimit {
+		return listLimit
+	}
+	return n
+}
+
+// quote returns an ETag in the double quotes of S3 responses.
+func quote(etag string) string {
+	return "\"" + etag + "\""
+}
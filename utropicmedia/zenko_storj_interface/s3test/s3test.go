@@ -0,0 +1,318 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package s3test runs an S3 server in memory that the minio client, the Tagger and the
+// VersionReader of the zenko package can talk to. Together with the memory package, which
+// stands in for Storj, it runs backups, restores, syncs and migrations end to end without
+// network access or credentials.
+//
+// The server implements the subset of S3 these clients use: buckets, listings (V1, V2 and
+// versions), objects with metadata, ranges, tags and versions, and multipart uploads.
+// Requests are addressed path-style; their signatures are not checked.
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/zenko"
+)
+
+// NullVersion is the version ID of objects written to a bucket without versioning.
+const NullVersion = "null"
+
+// Object is one version of an object stored by the server, or a delete marker.
+type Object struct {
+	Key       string
+	VersionID string
+	// DeleteMarker is set when the version records the deletion of the key and has no data.
+	DeleteMarker bool
+	Data         []byte
+	// Header holds the Content-Type, Cache-Control, Content-Disposition, Content-Encoding,
+	// Content-Language and X-Amz-Meta-* headers the object was written with.
+	Header http.Header
+	Tags   map[string]string
+	// ETag is the hex MD5 hash of Data, or for multipart uploads the hash of the part
+	// hashes followed by "-" and the number of parts.
+	ETag     string
+	Modified time.Time
+}
+
+// Error is an S3 error response.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// Error implements error.
+func (err *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", err.Status, err.Code, err.Message)
+}
+
+// Fault decides whether a request fails before it is served, so that error paths can be
+// exercised. A nil result serves the request; an *Error is sent as it is and any other
+// error as an InternalError. key is empty for requests on a bucket, bucket for ListBuckets.
+type Fault func(r *http.Request, bucket string, key string) error
+
+// Server is an in-memory S3 server listening on a local port. It is safe for concurrent use.
+type Server struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	uploads map[string]*upload
+	fault   Fault
+	nextID  int64
+}
+
+// bucket holds the versions of the keys of a bucket, newest first.
+type bucket struct {
+	created   time.Time
+	versioned bool
+	keys      map[string][]*Object
+}
+
+// upload is a multipart upload in progress.
+type upload struct {
+	bucket string
+	key    string
+	header http.Header
+	parts  map[int][]byte
+}
+
+// NewServer starts a Server without buckets. Close stops it.
+func NewServer() *Server {
+	server := &Server{
+		buckets: make(map[string]*bucket),
+		uploads: make(map[string]*upload),
+	}
+	server.server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// Close stops the server.
+func (server *Server) Close() {
+	server.server.Close()
+}
+
+// Endpoint returns the host and port the server listens on.
+func (server *Server) Endpoint() string {
+	return strings.TrimPrefix(server.server.URL, "http://")
+}
+
+// Config returns the configuration of an S3 source that reads from the server.
+func (server *Server) Config() zenko.ConfigS3 {
+	return zenko.ConfigS3{
+		Profile:          zenko.ProfileMinIO,
+		EndPoint:         server.Endpoint(),
+		AccessKeyID:      "s3test",
+		SecretAccessKey:  "s3test-secret",
+		Region:           zenko.DefaultRegion,
+		BucketLookup:     zenko.LookupPath,
+		SignatureVersion: zenko.SignatureV4,
+		DisableTLS:       true,
+	}
+}
+
+// Connect returns the clients of an S3 source that reads from the server.
+func (server *Server) Connect() (*zenko.ZenkoReader, error) {
+	return zenko.NewZenkoReader(server.Config())
+}
+
+// SetFault makes every following request pass through fault, nil to serve every request.
+func (server *Server) SetFault(fault Fault) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.fault = fault
+}
+
+// MakeBucket creates a bucket, unless it exists.
+func (server *Server) MakeBucket(name string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.makeBucket(name)
+}
+
+// EnableVersioning creates a bucket, unless it exists, and keeps every version of its objects
+// from then on.
+func (server *Server) EnableVersioning(name string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.makeBucket(name).versioned = true
+}
+
+// PutObject stores data under the key of a bucket, creating the bucket when it does not exist,
+// and returns the new version. header holds the metadata, as PUT request headers.
+func (server *Server) PutObject(bucketName string, key string, data []byte, header http.Header) Object {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	sum := md5.Sum(data)
+	return *server.put(server.makeBucket(bucketName), key, data, objectHeader(header), hex.EncodeToString(sum[:]))
+}
+
+// Object returns the current version of the key of a bucket. Keys whose current version is
+// a delete marker do not exist.
+func (server *Server) Object(bucketName string, key string) (Object, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	object := server.current(bucketName, key)
+	if object == nil {
+		return Object{}, false
+	}
+	return *object, true
+}
+
+// Versions returns every version and delete marker of the key of a bucket, newest first.
+func (server *Server) Versions(bucketName string, key string) []Object {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	var versions []Object
+	if bucket, ok := server.buckets[bucketName]; ok {
+		for _, object := range bucket.keys[key] {
+			versions = append(versions, *object)
+		}
+	}
+	return versions
+}
+
+// Keys returns the keys of a bucket that exist, in order.
+func (server *Server) Keys(bucketName string) []string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	var keys []string
+	if bucket, ok := server.buckets[bucketName]; ok {
+		for _, key := range bucket.sortedKeys() {
+			if !bucket.keys[key][0].DeleteMarker {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// makeBucket returns the bucket with the given name, creating it when it does not exist.
+func (server *Server) makeBucket(name string) *bucket {
+	b, ok := server.buckets[name]
+	if !ok {
+		b = &bucket{created: now(), keys: make(map[string][]*Object)}
+		server.buckets[name] = b
+	}
+	return b
+}
+
+// current returns the current version of a key, nil when the key does not exist.
+func (server *Server) current(bucketName string, key string) *Object {
+	bucket, ok := server.buckets[bucketName]
+	if !ok {
+		return nil
+	}
+	versions := bucket.keys[key]
+	if len(versions) == 0 || versions[0].DeleteMarker {
+		return nil
+	}
+	return versions[0]
+}
+
+// put adds a version to a key. Without versioning it replaces the null version.
+func (server *Server) put(bucket *bucket, key string, data []byte, header http.Header, etag string) *Object {
+	object := &Object{
+		Key:      key,
+		Data:     data,
+		Header:   header,
+		ETag:     etag,
+		Modified: now(),
+	}
+	server.addVersion(bucket, object)
+	return object
+}
+
+// remove deletes a key. With versioning it adds a delete marker instead.
+func (server *Server) remove(bucket *bucket, key string) {
+	if !bucket.versioned {
+		server.dropVersion(bucket, key, NullVersion)
+		return
+	}
+	if versions := bucket.keys[key]; len(versions) > 0 && !versions[0].DeleteMarker {
+		server.addVersion(bucket, &Object{Key: key, DeleteMarker: true, Modified: now()})
+	}
+}
+
+// addVersion makes object the current version of its key.
+func (server *Server) addVersion(bucket *bucket, object *Object) {
+	if bucket.versioned {
+		server.nextID++
+		object.VersionID = fmt.Sprintf("%016x", server.nextID)
+	} else {
+		object.VersionID = NullVersion
+		server.dropVersion(bucket, object.Key, NullVersion)
+	}
+	bucket.keys[object.Key] = append([]*Object{object}, bucket.keys[object.Key]...)
+}
+
+// dropVersion deletes one version of a key.
+func (server *Server) dropVersion(bucket *bucket, key string, versionID string) {
+	versions := bucket.keys[key]
+	for i, object := range versions {
+		if object.VersionID == versionID {
+			versions = append(versions[:i:i], versions[i+1:]...)
+			break
+		}
+	}
+	if len(versions) == 0 {
+		delete(bucket.keys, key)
+		return
+	}
+	bucket.keys[key] = versions
+}
+
+// sortedKeys returns every key with versions, in order.
+func (bucket *bucket) sortedKeys() []string {
+	keys := make([]string, 0, len(bucket.keys))
+	for key := range bucket.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// objectHeader returns the headers of a request that are stored as object metadata.
+func objectHeader(header http.Header) http.Header {
+	stored := make(http.Header)
+	for name, values := range header {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		switch {
+		case name == "Content-Type", name == "Cache-Control", name == "Content-Disposition",
+			name == "Content-Encoding", name == "Content-Language", strings.HasPrefix(name, "X-Amz-Meta-"):
+			stored[name] = append([]string(nil), values...)
+		}
+	}
+	// aws-chunked only describes how the request body was signed.
+	var encodings []string
+	for _, encoding := range strings.Split(stored.Get("Content-Encoding"), ",") {
+		if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != "aws-chunked" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	stored.Del("Content-Encoding")
+	if len(encodings) > 0 {
+		stored.Set("Content-Encoding", strings.Join(encodings, ","))
+	}
+	if stored.Get("Content-Type") == "" {
+		stored.Set("Content-Type", "application/octet-stream")
+	}
+	return stored
+}
+
+// now returns the current time with the millisecond precision of S3 listings.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+)
+
+// CheckpointFolder is the folder below the upload path that holds the checkpoints of unfinished runs.
+const CheckpointFolder = "checkpoints"
+
+// Checkpoint records the progress of an unfinished run of the store command, so that it can be resumed.
+type Checkpoint struct {
+	// Manifest is the manifest of the run, holding the objects completed so far.
+	Manifest Manifest `json:"manifest"`
+	// Chunking is the chunking configuration the run was started with.
+	Chunking chunker.Config `json:"chunking"`
+	// Partial lists the objects whose fixed chunks were only partly uploaded.
+	Partial []PartialObject `json:"partial,omitempty"`
+}
+
+// PartialObject records how many fixed chunks of an object were uploaded.
+type PartialObject struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	VersionID string `json:"versionId,omitempty"`
+	ETag      string `json:"etag"`
+	ChunkSize int64  `json:"chunkSize"`
+	Chunks    int    `json:"chunks"`
+	// Prefix is the folder holding the chunks, relative to the upload path.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// ID returns the ObjectID of the partly uploaded object.
+func (partial PartialObject) ID() string {
+	return ObjectID(partial.Bucket, partial.Key, partial.VersionID)
+}
+
+// CheckpointPath returns the Storj path of the checkpoint of the given run.
+func CheckpointPath(uploadPath string, runID string) string {
+	return UploadPrefix(uploadPath) + CheckpointFolder + "/" + runID + ".json"
+}
+
+// SaveCheckpoint uploads the checkpoint of a run, replacing the previous one.
+func SaveCheckpoint(ctx context.Context, bucket Destination, uploadPath string, checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(ctx, CheckpointPath(uploadPath, checkpoint.Manifest.RunID), bytes.NewReader(data), int64(len(data)), ObjectMetadata{
+		ContentType: "application/json",
+	})
+}
+
+// LoadCheckpoint downloads the checkpoint of the given run.
+func LoadCheckpoint(ctx context.Context, bucket Destination, uploadPath string, runID string) (*Checkpoint, error) {
+	_, strm, err := bucket.Get(ctx, CheckpointPath(uploadPath, runID), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = strm.Close() }()
+	data, err := ioutil.ReadAll(strm)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.Chunking.Strategy == "" {
+		checkpoint.Chunking = chunker.Config{Strategy: chunker.Fixed, Size: chunker.LegacyChunkSize}
+	}
+	checkpoint.Manifest.upgrade()
+	return &checkpoint, nil
+}
+
+// DeleteCheckpoint removes the checkpoint of a finished run.
+func DeleteCheckpoint(ctx context.Context, bucket Destination, uploadPath string, runID string) error {
+	return bucket.Delete(ctx, CheckpointPath(uploadPath, runID))
+}
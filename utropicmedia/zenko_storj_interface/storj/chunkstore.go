@@ -0,0 +1,119 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+)
+
+// ChunkFolder is the folder below the upload path that holds the content-addressed chunks
+// shared by all snapshots made with content-defined chunking.
+const ChunkFolder = "chunks"
+
+// ChunkList is the ordered list of content-addressed chunks an object was split into.
+type ChunkList struct {
+	Chunks []ChunkRef `json:"chunks"`
+	// Metadata is the metadata of the object, kept with the list since the chunks are shared.
+	Metadata *ObjectMetadata `json:"metadata,omitempty"`
+}
+
+// ChunkRef identifies a content-addressed chunk.
+type ChunkRef struct {
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// ChunkStorePath returns the Storj path of the chunk with the given content hash.
+func ChunkStorePath(uploadPath string, hash string) string {
+	return UploadPrefix(uploadPath) + ChunkFolder + "/" + hash
+}
+
+// ChunkStore uploads content-addressed chunks, skipping chunks that are already stored.
+// It is safe for concurrent use.
+type ChunkStore struct {
+	bucket     Destination
+	uploadPath string
+
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+// NewChunkStore returns a ChunkStore below the upload path of bucket.
+func NewChunkStore(bucket Destination, uploadPath string) *ChunkStore {
+	return &ChunkStore{
+		bucket:     bucket,
+		uploadPath: uploadPath,
+		known:      make(map[string]bool),
+	}
+}
+
+// Put stores a chunk under its SHA-256 hash. It reports whether the chunk had to be uploaded
+// or was already stored by this or an earlier run.
+func (store *ChunkStore) Put(ctx context.Context, data []byte) (ChunkRef, bool, error) {
+	sum := sha256.Sum256(data)
+	ref := ChunkRef{Hash: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	if store.isKnown(ref.Hash) {
+		return ref, false, nil
+	}
+
+	chunkPath := ChunkStorePath(store.uploadPath, ref.Hash)
+	if info, object, err := store.bucket.Get(ctx, chunkPath, 0); err == nil {
+		_ = object.Close()
+		if info.Size == ref.Size {
+			store.setKnown(ref.Hash)
+			return ref, false, nil
+		}
+	}
+
+	if err := store.bucket.Put(ctx, chunkPath, bytes.NewReader(data), ref.Size, ObjectMetadata{}); err != nil {
+		return ref, false, err
+	}
+	store.setKnown(ref.Hash)
+	return ref, true, nil
+}
+
+func (store *ChunkStore) isKnown(hash string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.known[hash]
+}
+
+func (store *ChunkStore) setKnown(hash string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.known[hash] = true
+}
+
+// SaveChunkList uploads the chunk list of an object to the given path below the upload path.
+func SaveChunkList(ctx context.Context, bucket Destination, uploadPath string, listPath string, list ChunkList) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(ctx, UploadPrefix(uploadPath)+listPath, bytes.NewReader(data), int64(len(data)), ObjectMetadata{
+		ContentType: "application/json",
+	})
+}
+
+// LoadChunkList downloads the chunk list stored at the given path below the upload path.
+func LoadChunkList(ctx context.Context, bucket Destination, uploadPath string, listPath string) (ChunkList, error) {
+	var list ChunkList
+	_, strm, err := bucket.Get(ctx, UploadPrefix(uploadPath)+listPath, 0)
+	if err != nil {
+		return list, err
+	}
+	defer func() { _ = strm.Close() }()
+	data, err := ioutil.ReadAll(strm)
+	if err != nil {
+		return list, err
+	}
+	err = json.Unmarshal(data, &list)
+	return list, err
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	libstorj "storj.io/common/storj"
+	"storj.io/storj/lib/uplink"
+)
+
+// ErrNotFound is returned, possibly wrapped, by Destination.Get when there is no object at the path.
+var ErrNotFound = errors.New("object not found")
+
+// Destination stores objects under slash-separated paths. Backups, manifests and checkpoints
+// are written to a Storj bucket through it and read back from it by restores, so that other
+// stores, such as a local directory or memory, can take the place of Storj.
+type Destination interface {
+	// Put writes an object at path with the given metadata, replacing any object stored there.
+	// size is the number of bytes data holds, -1 when it is not known in advance.
+	Put(ctx context.Context, path string, data io.Reader, size int64, metadata ObjectMetadata) error
+	// List returns the objects below prefix, in path order. Unless recursive is set, it lists
+	// the objects directly below prefix and the folders below it, as prefixes.
+	List(ctx context.Context, prefix string, recursive bool) ([]ObjectInfo, error)
+	// Get returns the object at path, with its metadata, and its data starting at offset.
+	Get(ctx context.Context, path string, offset int64) (ObjectInfo, io.ReadCloser, error)
+	// Delete removes the object at path.
+	Delete(ctx context.Context, path string) error
+}
+
+// Bucket is the Destination of an open Storj bucket.
+type Bucket struct {
+	bucket *uplink.Bucket
+}
+
+// NewBucket returns the Destination of an open Storj bucket, nil when bucket is nil.
+func NewBucket(bucket *uplink.Bucket) Destination {
+	if bucket == nil {
+		return nil
+	}
+	return &Bucket{bucket: bucket}
+}
+
+// Put implements Destination. The metadata is stored as described by ObjectMetadata.UploadOptions.
+func (bucket *Bucket) Put(ctx context.Context, path string, data io.Reader, size int64, metadata ObjectMetadata) error {
+	return bucket.bucket.UploadObject(ctx, path, data, metadata.UploadOptions())
+}
+
+// List implements Destination, following every page of the listing.
+func (bucket *Bucket) List(ctx context.Context, prefix string, recursive bool) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	options := uplink.ListOptions{
+		Direction: libstorj.After,
+		Prefix:    prefix,
+		Recursive: recursive,
+	}
+	for {
+		list, err := bucket.bucket.ListObjects(ctx, &options)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			objects = append(objects, ObjectInfo{
+				Path:     prefix + item.Path,
+				IsPrefix: item.IsPrefix,
+				Size:     item.Size,
+				Modified: item.Modified,
+			})
+		}
+		if !list.More || len(list.Items) == 0 {
+			return objects, nil
+		}
+		options.Cursor = list.Items[len(list.Items)-1].Path
+	}
+}
+
+// Get implements Destination. The data is only requested from the network when it is first read.
+func (bucket *Bucket) Get(ctx context.Context, path string, offset int64) (ObjectInfo, io.ReadCloser, error) {
+	object, err := bucket.bucket.OpenObject(ctx, path)
+	if libstorj.ErrObjectNotFound.Has(err) {
+		return ObjectInfo{}, nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return ObjectInfo{}, nil, err
+	}
+	info := ObjectInfo{
+		Path:     path,
+		Size:     object.Meta.Size,
+		Modified: object.Meta.Modified,
+		Metadata: MetadataFromStorj(object.Meta),
+	}
+	return info, &objectReader{ctx: ctx, object: object, offset: offset}, nil
+}
+
+// Delete implements Destination.
+func (bucket *Bucket) Delete(ctx context.Context, path string) error {
+	return bucket.bucket.DeleteObject(ctx, path)
+}
+
+// objectReader downloads the data of a Storj object once it is read.
+type objectReader struct {
+	ctx    context.Context
+	object *uplink.Object
+	offset int64
+	strm   io.ReadCloser
+}
+
+// Read implements io.Reader.
+func (reader *objectReader) Read(p []byte) (int, error) {
+	if reader.strm == nil {
+		strm, err := reader.object.DownloadRange(reader.ctx, reader.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		reader.strm = strm
+	}
+	return reader.strm.Read(p)
+}
+
+// Close implements io.Closer.
+func (reader *objectReader) Close() error {
+	var err error
+	if reader.strm != nil {
+		err = reader.strm.Close()
+	}
+	if closeErr := reader.object.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
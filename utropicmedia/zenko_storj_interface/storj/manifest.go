@@ -0,0 +1,333 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/filter"
+)
+
+// ManifestFolder is the folder below the upload path that holds the manifest of every run.
+const ManifestFolder = "manifests"
+
+// VersionFolder is the folder below a snapshot folder that holds the object versions
+// copied by a run with versions.
+const VersionFolder = ".versions"
+
+// Manifest records what a single run of the store command copied to Storj.
+type Manifest struct {
+	RunID   string    `json:"runID"`
+	Version string    `json:"version"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// Base is the run an incremental run was compared against.
+	Base string `json:"base,omitempty"`
+	// Filter holds the include and exclude rules the run selected buckets and keys with.
+	Filter *filter.Config `json:"filter,omitempty"`
+	// Versions is set when the run copied every version and delete marker of the objects,
+	// not only their current versions.
+	Versions bool `json:"versions,omitempty"`
+	// Dir is the local directory a run of the filesystem source read its files from.
+	// The files are recorded under the single bucket in Buckets.
+	Dir string `json:"dir,omitempty"`
+	// Buckets lists every Zenko bucket the run covered, including empty ones.
+	Buckets []string        `json:"buckets"`
+	Objects []ManifestEntry `json:"objects"`
+	// Failures lists the objects the run skipped because they could not be copied.
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// Failure records an object that a run could not copy.
+type Failure struct {
+	Bucket string `json:"bucket"`
+	// Key is the object key; for a failed listing it is the last key listed before the failure.
+	Key string `json:"key"`
+	// VersionID identifies the object version that failed in a run with versions.
+	VersionID string `json:"versionId,omitempty"`
+	// Stage is the step that failed: list, stat (which includes reading the tags), read or upload.
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+// ManifestEntry describes one Zenko object copied by a run.
+type ManifestEntry struct {
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+	// VersionID identifies the version of the object copied by a run with versions.
+	VersionID string `json:"versionId,omitempty"`
+	// IsLatest is set for the current version of the key.
+	IsLatest bool `json:"isLatest,omitempty"`
+	// DeleteMarker is set for a version that records the deletion of the key.
+	// Delete markers have no data and no chunks.
+	DeleteMarker bool `json:"deleteMarker,omitempty"`
+	// ObjectMetadata holds the content type, the other HTTP headers and the user metadata of the object.
+	ObjectMetadata
+	// Chunking is the chunker strategy the object was stored with. Older versions split objects into
+	// chunker.LegacyChunkSize chunks without recording it, their entries are loaded as fixed chunking.
+	Chunking string `json:"chunking,omitempty"`
+	// ChunkSize is the size of every chunk but the last one for fixed chunking.
+	ChunkSize int64 `json:"chunkSize,omitempty"`
+	// Chunks is the number of chunks the object was split into.
+	Chunks int `json:"chunks"`
+	// Prefix is the folder holding the chunks, relative to the upload path.
+	// For objects stored without chunking it is the path of the Storj object itself,
+	// for content-defined chunking the path of the object's chunk list.
+	Prefix string `json:"prefix"`
+	// Extension is the file extension of the chunks uploaded by older versions as "<i>.<extension>",
+	// empty for chunks named "<i>", see ChunkName.
+	Extension string `json:"extension"`
+	// Reference is the run that uploaded the chunks when the object was unchanged
+	// since an earlier run and was not transferred again.
+	Reference string `json:"reference,omitempty"`
+}
+
+// Unchanged reports whether the object described by size, etag and lastModified
+// is the same one recorded in the entry.
+func (entry ManifestEntry) Unchanged(size int64, etag string, lastModified time.Time) bool {
+	return entry.Size == size && entry.ETag == etag && entry.LastModified.Equal(lastModified)
+}
+
+// Current reports whether the entry is the current version of its key, which is
+// the object a restore writes back. Entries of runs without versions always are.
+func (entry ManifestEntry) Current() bool {
+	return (entry.VersionID == "" || entry.IsLatest) && !entry.DeleteMarker
+}
+
+// ObjectID identifies an object in Manifest.Index: "<zenkoBucket>/<key>",
+// followed by "?versionId=<versionID>" for object versions.
+func ObjectID(bucket string, key string, versionID string) string {
+	if versionID == "" {
+		return bucket + "/" + key
+	}
+	return bucket + "/" + key + "?versionId=" + versionID
+}
+
+// ID returns the ObjectID of the entry.
+func (entry ManifestEntry) ID() string {
+	return ObjectID(entry.Bucket, entry.Key, entry.VersionID)
+}
+
+// VersionKey returns the key, relative to the snapshot folder, that a version of a
+// Zenko object is stored under: "<VersionFolder>/<key>/<versionID>". All versions of
+// a key are kept next to each other.
+func VersionKey(key string, versionID string) string {
+	return VersionFolder + "/" + key + "/" + versionID
+}
+
+// Index returns the entries of the manifest keyed by their ObjectID.
+func (manifest *Manifest) Index() map[string]ManifestEntry {
+	index := make(map[string]ManifestEntry, len(manifest.Objects))
+	for _, entry := range manifest.Objects {
+		index[entry.ID()] = entry
+	}
+	return index
+}
+
+// ChunkPaths returns the full Storj paths of the chunks of the entry, in order.
+// Content-defined chunks are not known without their chunk list, see SnapshotObjects.
+func (entry ManifestEntry) ChunkPaths(uploadPath string) []string {
+	if entry.Chunking == chunker.None {
+		return []string{UploadPrefix(uploadPath) + entry.Prefix}
+	}
+	chunks := make([]string, 0, entry.Chunks)
+	for i := 0; i < entry.Chunks; i++ {
+		chunks = append(chunks, UploadPrefix(uploadPath)+entry.Prefix+"/"+ChunkName(i, entry.Extension))
+	}
+	return chunks
+}
+
+// ManifestPath returns the Storj path of the manifest of the given run.
+func ManifestPath(uploadPath string, runID string) string {
+	return UploadPrefix(uploadPath) + ManifestFolder + "/" + runID + ".json"
+}
+
+// SaveManifest uploads the manifest of a run to Storj.
+func SaveManifest(ctx context.Context, bucket Destination, uploadPath string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return bucket.Put(ctx, ManifestPath(uploadPath, manifest.RunID), bytes.NewReader(data), int64(len(data)), ObjectMetadata{
+		ContentType: "application/json",
+	})
+}
+
+// ListManifests returns the run IDs of all manifests stored below the upload path, oldest first.
+func ListManifests(ctx context.Context, bucket Destination, uploadPath string) ([]string, error) {
+	manifestPrefix := UploadPrefix(uploadPath) + ManifestFolder + "/"
+	items, err := bucket.List(ctx, manifestPrefix, false)
+	if err != nil {
+		return nil, err
+	}
+	var runIDs []string
+	for _, item := range items {
+		if item.IsPrefix || !strings.HasSuffix(item.Path, ".json") {
+			continue
+		}
+		runIDs = append(runIDs, strings.TrimSuffix(strings.TrimPrefix(item.Path, manifestPrefix), ".json"))
+	}
+	sort.Strings(runIDs)
+	return runIDs, nil
+}
+
+// LoadManifest downloads the manifest of the given run.
+// It returns nil without an error when the run has no manifest, as for runs made by older versions.
+func LoadManifest(ctx context.Context, bucket Destination, uploadPath string, runID string) (*Manifest, error) {
+	runIDs, err := ListManifests(ctx, bucket, uploadPath)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, id := range runIDs {
+		if id == runID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return downloadManifest(ctx, bucket, uploadPath, runID)
+}
+
+// LatestManifest downloads the manifest of the most recent run.
+// It returns nil without an error when no run has a manifest yet.
+func LatestManifest(ctx context.Context, bucket Destination, uploadPath string) (*Manifest, error) {
+	runIDs, err := ListManifests(ctx, bucket, uploadPath)
+	if err != nil || len(runIDs) == 0 {
+		return nil, err
+	}
+	return downloadManifest(ctx, bucket, uploadPath, runIDs[len(runIDs)-1])
+}
+
+// downloadManifest downloads and decodes the manifest of the given run.
+func downloadManifest(ctx context.Context, bucket Destination, uploadPath string, runID string) (*Manifest, error) {
+	_, strm, err := bucket.Get(ctx, ManifestPath(uploadPath, runID), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = strm.Close() }()
+	data, err := ioutil.ReadAll(strm)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	manifest.upgrade()
+	return &manifest, nil
+}
+
+// upgrade fills in the chunking of the entries of older versions, which did not record it.
+func (manifest *Manifest) upgrade() {
+	for i := range manifest.Objects {
+		entry := &manifest.Objects[i]
+		if entry.Chunking == "" && !entry.DeleteMarker {
+			entry.Chunking = chunker.Fixed
+			entry.ChunkSize = chunker.LegacyChunkSize
+		}
+	}
+}
+
+// SnapshotObjects returns the objects of a snapshot. Snapshot.Manifest is used when it is set,
+// so keys are restored exactly; older snapshots fall back to parsing the chunk paths.
+// Of snapshots with versions only the current versions are returned.
+func SnapshotObjects(ctx context.Context, bucket Destination, uploadPath string, snapshot Snapshot) ([]ChunkedObject, error) {
+	return snapshotObjects(ctx, bucket, uploadPath, snapshot, false)
+}
+
+// SnapshotVersions returns every version and delete marker of the objects of a snapshot,
+// ordered by key and, within a key, oldest first. Snapshots without versions hold one
+// version per object and return the same objects as SnapshotObjects.
+func SnapshotVersions(ctx context.Context, bucket Destination, uploadPath string, snapshot Snapshot) ([]ChunkedObject, error) {
+	return snapshotObjects(ctx, bucket, uploadPath, snapshot, true)
+}
+
+// snapshotObjects returns the objects of a snapshot, only the current versions unless all is set.
+func snapshotObjects(ctx context.Context, bucket Destination, uploadPath string, snapshot Snapshot, all bool) ([]ChunkedObject, error) {
+	manifest := snapshot.Manifest
+	if manifest == nil {
+		return ListChunkedObjects(ctx, bucket, snapshot.Prefix)
+	}
+
+	var objects []ChunkedObject
+	for _, entry := range manifest.Objects {
+		if entry.Bucket != snapshot.Bucket || !all && !entry.Current() {
+			continue
+		}
+		if entry.DeleteMarker {
+			objects = append(objects, ChunkedObject{
+				Key:          entry.Key,
+				VersionID:    entry.VersionID,
+				IsLatest:     entry.IsLatest,
+				DeleteMarker: true,
+				LastModified: entry.LastModified,
+			})
+			continue
+		}
+		chunks := entry.ChunkPaths(uploadPath)
+		if entry.Chunking == chunker.Content {
+			list, err := LoadChunkList(ctx, bucket, uploadPath, entry.Prefix)
+			if err != nil {
+				return nil, err
+			}
+			chunks = make([]string, 0, len(list.Chunks))
+			for _, ref := range list.Chunks {
+				chunks = append(chunks, ChunkStorePath(uploadPath, ref.Hash))
+			}
+		}
+		objects = append(objects, ChunkedObject{
+			Prefix:       UploadPrefix(uploadPath) + entry.Prefix,
+			Key:          entry.Key,
+			Chunks:       chunks,
+			Size:         entry.Size,
+			Metadata:     entry.ObjectMetadata,
+			VersionID:    entry.VersionID,
+			IsLatest:     entry.IsLatest,
+			LastModified: entry.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+// SnapshotSummary returns the number of objects of a snapshot and their total size from
+// Snapshot.Manifest, without downloading the chunk lists of content-defined chunks. Of snapshots
+// with versions only the current versions are counted.
+func SnapshotSummary(ctx context.Context, bucket Destination, snapshot Snapshot) (int, int64, error) {
+	var count int
+	var size int64
+	manifest := snapshot.Manifest
+	if manifest == nil {
+		objects, err := ListChunkedObjects(ctx, bucket, snapshot.Prefix)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, object := range objects {
+			count++
+			size += object.Size
+		}
+		return count, size, nil
+	}
+
+	for _, entry := range manifest.Objects {
+		if entry.Bucket == snapshot.Bucket && entry.Current() {
+			count++
+			size += entry.Size
+		}
+	}
+	return count, size, nil
+}
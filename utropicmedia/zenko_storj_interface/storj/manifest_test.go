@@ -0,0 +1,60 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"utropicmedia/zenko_storj_interface/chunker"
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+// legacyManifest is a manifest written before the chunking was recorded.
+const legacyManifest = `{"runID": "2020-01-02_03_04_05", "buckets": ["photos"], "objects": [
+	{"bucket": "photos", "key": "a.jpg", "size": 40000, "chunks": 2, "prefix": "photos_2020-01-02_03_04_05/a", "extension": "jpg"},
+	{"bucket": "photos", "key": "b.jpg", "size": 10, "chunking": "none", "chunks": 1, "prefix": "photos_2020-01-02_03_04_05/b.jpg"},
+	{"bucket": "photos", "key": "c.jpg", "deleteMarker": true, "versionId": "2", "chunks": 0}
+]}`
+
+func TestLoadLegacyManifest(t *testing.T) {
+	ctx := context.Background()
+	bucket := memory.NewBucket()
+	bucket.PutObject(storj.ManifestPath("backup", "2020-01-02_03_04_05"), []byte(legacyManifest), storj.ObjectMetadata{})
+
+	manifest, err := storj.LoadManifest(ctx, bucket, "backup", "2020-01-02_03_04_05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range manifest.Objects {
+		got = append(got, entry.Chunking)
+	}
+	if want := []string{chunker.Fixed, chunker.None, ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries loaded with chunking %q, want %q", got, want)
+	}
+	entry := manifest.Objects[0]
+	if entry.ChunkSize != chunker.LegacyChunkSize {
+		t.Errorf("legacy entry loaded with chunk size %d, want %d", entry.ChunkSize, chunker.LegacyChunkSize)
+	}
+	paths := []string{"backup/photos_2020-01-02_03_04_05/a/0.jpg", "backup/photos_2020-01-02_03_04_05/a/1.jpg"}
+	if !reflect.DeepEqual(entry.ChunkPaths("backup"), paths) {
+		t.Errorf("legacy entry has chunks %q, want %q", entry.ChunkPaths("backup"), paths)
+	}
+
+	// A checkpoint without chunking is loaded the same way.
+	bucket.PutObject(storj.CheckpointPath("backup", "2020-01-02_03_04_05"), []byte(`{"manifest": `+legacyManifest+`}`), storj.ObjectMetadata{})
+	checkpoint, err := storj.LoadCheckpoint(ctx, bucket, "backup", "2020-01-02_03_04_05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (chunker.Config{Strategy: chunker.Fixed, Size: chunker.LegacyChunkSize}); checkpoint.Chunking != want {
+		t.Errorf("checkpoint loaded with chunking %+v, want %+v", checkpoint.Chunking, want)
+	}
+	if checkpoint.Manifest.Objects[0].Chunking != chunker.Fixed {
+		t.Errorf("checkpoint entry loaded with chunking %q, want %q", checkpoint.Manifest.Objects[0].Chunking, chunker.Fixed)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"storj.io/storj/lib/uplink"
+)
+
+// UserMetadataPrefix is the header prefix of S3 user metadata.
+const UserMetadataPrefix = "X-Amz-Meta-"
+
+// TaggingHeader is the S3 header holding the URL-encoded tag set of an object.
+const TaggingHeader = "X-Amz-Tagging"
+
+// User metadata recording the attributes of a file backed up from the local filesystem.
+const (
+	// FilePathMetadata is the path of the file relative to the backed-up directory, with slashes.
+	FilePathMetadata = "File-Path"
+	// FileModTimeMetadata is the modification time of the file in RFC 3339 format, with nanoseconds.
+	FileModTimeMetadata = "File-Mtime"
+	// FileModeMetadata is the permission bits of the file in octal, e.g. "0644".
+	FileModeMetadata = "File-Mode"
+)
+
+// ObjectMetadata holds the HTTP headers and the user metadata of a Zenko object
+// that are kept with its backup and written back on restore.
+type ObjectMetadata struct {
+	ContentType        string `json:"contentType"`
+	CacheControl       string `json:"cacheControl,omitempty"`
+	ContentDisposition string `json:"contentDisposition,omitempty"`
+	ContentEncoding    string `json:"contentEncoding,omitempty"`
+	ContentLanguage    string `json:"contentLanguage,omitempty"`
+	// UserMetadata holds the x-amz-meta-* headers, keyed by the name after the prefix.
+	UserMetadata map[string]string `json:"userMetadata,omitempty"`
+	// Tags is the tag set of the object.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// UploadOptions returns the options that store the metadata with a Storj object.
+// The headers keep their HTTP names, user metadata keeps its x-amz-meta- prefix
+// and the tags are URL-encoded into x-amz-tagging.
+func (metadata ObjectMetadata) UploadOptions() *uplink.UploadOptions {
+	headers := make(map[string]string)
+	for name, value := range map[string]string{
+		"Cache-Control":       metadata.CacheControl,
+		"Content-Disposition": metadata.ContentDisposition,
+		"Content-Encoding":    metadata.ContentEncoding,
+		"Content-Language":    metadata.ContentLanguage,
+	} {
+		if value != "" {
+			headers[name] = value
+		}
+	}
+	for name, value := range metadata.UserMetadata {
+		headers[UserMetadataPrefix+name] = value
+	}
+	if len(metadata.Tags) > 0 {
+		tags := make(url.Values)
+		for name, value := range metadata.Tags {
+			tags.Set(name, value)
+		}
+		headers[TaggingHeader] = tags.Encode()
+	}
+	return &uplink.UploadOptions{
+		ContentType: metadata.ContentType,
+		Metadata:    headers,
+	}
+}
+
+// MetadataFromStorj returns the metadata stored with a Storj object by UploadOptions.
+func MetadataFromStorj(meta uplink.ObjectMeta) ObjectMetadata {
+	metadata := ObjectMetadata{
+		ContentType:        meta.ContentType,
+		CacheControl:       meta.Metadata["Cache-Control"],
+		ContentDisposition: meta.Metadata["Content-Disposition"],
+		ContentEncoding:    meta.Metadata["Content-Encoding"],
+		ContentLanguage:    meta.Metadata["Content-Language"],
+	}
+	if tags, err := url.ParseQuery(meta.Metadata[TaggingHeader]); err == nil && len(tags) > 0 {
+		metadata.Tags = make(map[string]string, len(tags))
+		for name := range tags {
+			metadata.Tags[name] = tags.Get(name)
+		}
+	}
+	for name, value := range meta.Metadata {
+		if strings.HasPrefix(name, UserMetadataPrefix) {
+			if metadata.UserMetadata == nil {
+				metadata.UserMetadata = make(map[string]string)
+			}
+			metadata.UserMetadata[strings.TrimPrefix(name, UserMetadataPrefix)] = value
+		}
+	}
+	return metadata
+}
+
+// FileAttributes returns the permissions and the modification time recorded with a file
+// of the local filesystem. ok is false for objects that were not backed up from a file.
+func (metadata ObjectMetadata) FileAttributes() (mode os.FileMode, modTime time.Time, ok bool) {
+	perm, err := strconv.ParseUint(metadata.UserMetadata[FileModeMetadata], 8, 32)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	modTime, err = time.Parse(time.RFC3339Nano, metadata.UserMetadata[FileModTimeMetadata])
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return os.FileMode(perm) & os.ModePerm, modTime, true
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MirrorFolder is the folder below the upload path that holds the mirrors kept by the sync command.
+// The objects of a Zenko bucket are mirrored to "<MirrorFolder>/<zenkoBucket>/<key>".
+const MirrorFolder = "mirror"
+
+// MirrorState records the objects of a mirror, so that the next sync only uploads changed objects.
+type MirrorState struct {
+	Bucket  string    `json:"bucket"`
+	Updated time.Time `json:"updated"`
+	// Objects describes the mirrored objects, ordered by key. Their Prefix is the path
+	// of the Storj object, relative to the upload path.
+	Objects []ManifestEntry `json:"objects"`
+}
+
+// MirrorPrefix returns the full Storj path of the folder mirroring a Zenko bucket, with a trailing slash.
+func MirrorPrefix(uploadPath string, zenkoBucket string) string {
+	return UploadPrefix(uploadPath) + MirrorFolder + "/" + zenkoBucket + "/"
+}
+
+// MirrorStatePath returns the Storj path of the state of the mirror of a Zenko bucket.
+func MirrorStatePath(uploadPath string, zenkoBucket string) string {
+	return UploadPrefix(uploadPath) + MirrorFolder + "/" + zenkoBucket + ".json"
+}
+
+// Index returns the entries of the mirror keyed by object key.
+func (state *MirrorState) Index() map[string]ManifestEntry {
+	index := make(map[string]ManifestEntry, len(state.Objects))
+	for _, entry := range state.Objects {
+		index[entry.Key] = entry
+	}
+	return index
+}
+
+// SetObjects replaces the entries of the mirror, keeping them ordered by key.
+func (state *MirrorState) SetObjects(index map[string]ManifestEntry) {
+	state.Objects = make([]ManifestEntry, 0, len(index))
+	for _, entry := range index {
+		state.Objects = append(state.Objects, entry)
+	}
+	sort.Slice(state.Objects, func(i, j int) bool { return state.Objects[i].Key < state.Objects[j].Key })
+}
+
+// LoadMirrorState downloads the state of the mirror of a Zenko bucket.
+// It returns an empty state when the bucket was never synced.
+func LoadMirrorState(ctx context.Context, bucket Destination, uploadPath string, zenkoBucket string) (*MirrorState, error) {
+	statePath := MirrorStatePath(uploadPath, zenkoBucket)
+	items, err := bucket.List(ctx, UploadPrefix(uploadPath)+MirrorFolder+"/", false)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, item := range items {
+		if !item.IsPrefix && item.Path == statePath {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return &MirrorState{Bucket: zenkoBucket}, nil
+	}
+
+	_, strm, err := bucket.Get(ctx, statePath, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = strm.Close() }()
+	data, err := ioutil.ReadAll(strm)
+	if err != nil {
+		return nil, err
+	}
+	var state MirrorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveMirrorState uploads the state of a mirror, replacing the previous one.
+func SaveMirrorState(ctx context.Context, bucket Destination, uploadPath string, state *MirrorState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return bucket.Put(ctx, MirrorStatePath(uploadPath, state.Bucket), bytes.NewReader(data), int64(len(data)), ObjectMetadata{
+		ContentType: "application/json",
+	})
+}
+
+// ListMirrorKeys returns the keys of the objects stored in the mirror of a Zenko bucket.
+func ListMirrorKeys(ctx context.Context, bucket Destination, uploadPath string, zenkoBucket string) ([]string, error) {
+	prefix := MirrorPrefix(uploadPath, zenkoBucket)
+	items, err := bucket.List(ctx, prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if !item.IsPrefix {
+			keys = append(keys, strings.TrimPrefix(item.Path, prefix))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
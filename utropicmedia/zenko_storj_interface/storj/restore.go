@@ -0,0 +1,385 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/retry"
+)
+
+// SnapshotTimeFormat is the layout of the timestamp appended to the Zenko bucket name
+// for every run of the store command.
+const SnapshotTimeFormat = "2006-01-02_15_04_05"
+
+// Policies for files that already exist in the target directory of RestoreToDir.
+const (
+	// ExistingFail aborts the restore when a file already exists.
+	ExistingFail = "fail"
+	// ExistingSkip keeps the existing file and continues with the next object.
+	ExistingSkip = "skip"
+	// ExistingOverwrite replaces the existing file.
+	ExistingOverwrite = "overwrite"
+)
+
+// ObjectInfo describes an object (or a prefix) found in the Storj bucket.
+type ObjectInfo struct {
+	Path     string
+	IsPrefix bool
+	Size     int64
+	Modified time.Time
+	// Metadata is the metadata stored with the object. It is only set by Destination.Get.
+	Metadata ObjectMetadata
+}
+
+// ChunkedObject is a Zenko object stored as numbered chunks below a common prefix.
+type ChunkedObject struct {
+	// Prefix is the full Storj path of the folder holding the chunks.
+	Prefix string
+	// Key is the Zenko object key, relative to the snapshot folder.
+	Key string
+	// Chunks holds the full Storj paths of the chunks, in upload order.
+	Chunks []string
+	// Size is the total size of all chunks.
+	Size int64
+	// Metadata is the metadata of the Zenko object, empty for snapshots without a manifest.
+	Metadata ObjectMetadata
+	// VersionID, IsLatest and LastModified describe the object version of snapshots with versions.
+	VersionID    string
+	IsLatest     bool
+	LastModified time.Time
+	// DeleteMarker is set for a version that records the deletion of the key; it has no chunks.
+	DeleteMarker bool
+}
+
+// FileKey returns the key, relative to the restore directory, that the object is written to.
+// Current objects keep their key; older versions are written below VersionFolder like in Storj,
+// so that they do not replace the current version.
+func (object ChunkedObject) FileKey() string {
+	if object.VersionID != "" && !object.IsLatest {
+		return VersionKey(object.Key, object.VersionID)
+	}
+	return object.Key
+}
+
+// UploadPrefix returns the upload path of the configuration with a trailing slash.
+func UploadPrefix(uploadPath string) string {
+	if uploadPath == "" || strings.HasSuffix(uploadPath, "/") {
+		return uploadPath
+	}
+	return uploadPath + "/"
+}
+
+// ChunkPrefix returns the folder, relative to the snapshot folder, under which the store command
+// uploads the fixed chunks of the given Zenko key: the key itself, with every path segment
+// escaped with url.PathEscape. Every key gets a folder of its own, named after all of the key.
+func ChunkPrefix(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// ChunkName returns the file name of the chunk with the given index, "<i>", or "<i>.<extension>"
+// for the chunks uploaded by older versions.
+func ChunkName(index int, extension string) string {
+	if extension == "" {
+		return strconv.Itoa(index)
+	}
+	return strconv.Itoa(index) + "." + extension
+}
+
+// OriginalKey returns the Zenko key of a chunk folder and extension written by older versions,
+// which uploaded the chunks below the key without its extension. Keys with more than two dots
+// in the file name cannot be recovered exactly, and keys such as "a" and "a.a" shared a folder.
+func OriginalKey(prefix string, fileExtension string) string {
+	if path.Base(prefix) == fileExtension {
+		return prefix
+	}
+	return prefix + "." + fileExtension
+}
+
+// ParseSnapshotName splits a "<zenkoBucket>_<time>" folder name into the Zenko bucket and time.
+func ParseSnapshotName(name string) (string, time.Time, bool) {
+	name = strings.TrimSuffix(name, "/")
+	if len(name) < len(SnapshotTimeFormat)+2 || name[len(name)-len(SnapshotTimeFormat)-1] != '_' {
+		return "", time.Time{}, false
+	}
+	timestamp, err := time.Parse(SnapshotTimeFormat, name[len(name)-len(SnapshotTimeFormat):])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return name[:len(name)-len(SnapshotTimeFormat)-1], timestamp, true
+}
+
+// ListChunkedObjects groups the chunks found below a snapshot folder into the objects they belong to.
+// Chunks named "<i>" are below the escaped key, see ChunkPrefix; chunks named "<i>.<extension>" were
+// uploaded by older versions and are grouped by their folder and extension, see OriginalKey.
+func ListChunkedObjects(ctx context.Context, bucket Destination, snapshotPrefix string) ([]ChunkedObject, error) {
+	snapshotPrefix = UploadPrefix(snapshotPrefix)
+	items, err := bucket.List(ctx, snapshotPrefix, true)
+	if err != nil {
+		return nil, err
+	}
+
+	type chunk struct {
+		index int
+		path  string
+		size  int64
+	}
+	type group struct {
+		folder    string
+		extension string
+	}
+	chunks := make(map[group][]chunk)
+	for _, item := range items {
+		folder, name := path.Split(item.Path)
+		if folder == "" {
+			continue
+		}
+		number, extension := name, ""
+		if dot := strings.Index(name, "."); dot >= 0 {
+			number, extension = name[:dot], name[dot+1:]
+		}
+		index, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		object := group{folder: strings.TrimSuffix(folder, "/"), extension: extension}
+		chunks[object] = append(chunks[object], chunk{index: index, path: item.Path, size: item.Size})
+	}
+
+	objects := make([]ChunkedObject, 0, len(chunks))
+	for group, parts := range chunks {
+		sort.Slice(parts, func(i, j int) bool { return parts[i].index < parts[j].index })
+		folder := strings.TrimPrefix(group.folder, snapshotPrefix)
+		key := OriginalKey(folder, group.extension)
+		if group.extension == "" {
+			if key, err = url.PathUnescape(folder); err != nil {
+				return nil, fmt.Errorf("chunk folder %q: %v", group.folder, err)
+			}
+		}
+		object := ChunkedObject{
+			Prefix: group.folder,
+			Key:    key,
+		}
+		for i, part := range parts {
+			if part.index != i {
+				return nil, fmt.Errorf("object %q is missing chunk %d", key, i)
+			}
+			object.Chunks = append(object.Chunks, part.path)
+			object.Size += part.size
+		}
+		objects = append(objects, object)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// chunkReader reads the chunks of an object one after the other,
+// opening the next chunk only when the previous one is exhausted.
+type chunkReader struct {
+	ctx     context.Context
+	bucket  Destination
+	chunks  []string
+	current io.ReadCloser
+}
+
+// NewChunkReader returns a reader over the concatenated contents of the given chunks.
+func NewChunkReader(ctx context.Context, bucket Destination, chunks []string) io.ReadCloser {
+	return &chunkReader{ctx: ctx, bucket: bucket, chunks: chunks}
+}
+
+// Read implements io.Reader.
+func (reader *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if reader.current == nil {
+			if len(reader.chunks) == 0 {
+				return 0, io.EOF
+			}
+			_, strm, err := reader.bucket.Get(reader.ctx, reader.chunks[0], 0)
+			if err != nil {
+				return 0, fmt.Errorf("could not open chunk %q: %v", reader.chunks[0], err)
+			}
+			reader.current = strm
+			reader.chunks = reader.chunks[1:]
+		}
+		n, err := reader.current.Read(p)
+		if err == io.EOF {
+			err = reader.current.Close()
+			reader.current = nil
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close implements io.Closer.
+func (reader *chunkReader) Close() error {
+	if reader.current == nil {
+		return nil
+	}
+	err := reader.current.Close()
+	reader.current = nil
+	return err
+}
+
+// Restore writes the given objects to target, such as a zenko.Destination, under their keys
+// and with their metadata, in order. A delete marker deletes its key, which recreates the
+// delete marker in a versioned bucket. Failed objects are restored again from the start with
+// the given policy.
+func Restore(ctx context.Context, bucket Destination, objects []ChunkedObject, target Destination, policy *retry.Policy) error {
+	for _, object := range objects {
+		if object.DeleteMarker {
+			fmt.Printf("\nRestoring delete marker %s of %s...\n", object.VersionID, object.Key)
+			err := policy.Do(ctx, "delete "+object.Key, func() error {
+				return target.Delete(ctx, object.Key)
+			})
+			if err != nil {
+				return fmt.Errorf("could not delete %q: %v", object.Key, err)
+			}
+			continue
+		}
+
+		fmt.Printf("\nRestoring %s (%d chunks)...\n", object.Key, len(object.Chunks))
+		err := policy.Do(ctx, "restore "+object.Key, func() error {
+			// Every attempt reads the chunks from the start.
+			reader := NewChunkReader(ctx, bucket, object.Chunks)
+			defer func() { _ = reader.Close() }()
+			return target.Put(ctx, object.Key, reader, object.Size, object.Metadata)
+		})
+		if err != nil {
+			return fmt.Errorf("could not restore %q: %v", object.Key, err)
+		}
+		fmt.Printf("%d bytes restored to %s\n", object.Size, object.Key)
+	}
+	return nil
+}
+
+// RestoreToDir downloads the given objects into the directory root, recreating the folder
+// structure of their keys. Every file is written to a temporary file first and renamed once
+// it is complete, so an interrupted restore never leaves a truncated file behind.
+// Failed downloads are retried from the start with the given policy. Delete markers are
+// skipped and older object versions are written to their FileKey. Files backed up from the
+// local filesystem are given back the permissions and modification time recorded with them,
+// other files are created with mode 0644 less the umask.
+func RestoreToDir(ctx context.Context, bucket Destination, objects []ChunkedObject, root string, existing string, policy *retry.Policy) error {
+	switch existing {
+	case ExistingFail, ExistingSkip, ExistingOverwrite:
+	default:
+		return fmt.Errorf("unknown policy for existing files %q", existing)
+	}
+
+	for _, object := range objects {
+		if object.DeleteMarker {
+			continue
+		}
+		fileName, err := RestorePath(root, object.FileKey())
+		if err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(fileName); err == nil {
+			switch existing {
+			case ExistingSkip:
+				fmt.Println("Skipping existing file: ", fileName)
+				continue
+			case ExistingFail:
+				return fmt.Errorf("file %q already exists", fileName)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		fmt.Printf("\nDownloading Object %s (%d chunks) to %s...\n", object.Key, len(object.Chunks), fileName)
+		var n int64
+		err = policy.Do(ctx, "download "+object.Key, func() (err error) {
+			n, err = downloadToFile(ctx, bucket, object, fileName)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d bytes of Object from bucket!\n", n)
+
+		// Files backed up from the local filesystem get their permissions and modification time back.
+		if mode, modTime, ok := object.Metadata.FileAttributes(); ok {
+			if err := os.Chmod(fileName, mode); err != nil {
+				return err
+			}
+			if err := os.Chtimes(fileName, modTime, modTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RestorePath returns the file an object key is restored to below the directory root.
+func RestorePath(root string, key string) (string, error) {
+	root = filepath.Clean(root)
+	fileName := filepath.Join(root, filepath.FromSlash(key))
+	if fileName != root && !strings.HasPrefix(fileName, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("object key %q escapes the target directory", key)
+	}
+	return fileName, nil
+}
+
+// downloadToFile writes the reassembled chunks of an object to fileName.
+func downloadToFile(ctx context.Context, bucket Destination, object ChunkedObject, fileName string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return 0, err
+	}
+
+	file, err := createTempFile(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	reader := NewChunkReader(ctx, bucket, object.Chunks)
+	n, err := io.Copy(file, reader)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, fmt.Errorf("could not download %q: %v", object.Key, err)
+	}
+	if n != object.Size {
+		return n, fmt.Errorf("downloaded %d bytes of %q, expected %d", n, object.Key, object.Size)
+	}
+	return n, os.Rename(file.Name(), fileName)
+}
+
+// createTempFile creates a new temporary file next to fileName. Unlike ioutil.TempFile, which
+// creates files with mode 0600, the file is created with mode 0644 less the umask, the mode the
+// restored file keeps unless metadata of the local filesystem records another one.
+func createTempFile(fileName string) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(fileName), "."+filepath.Base(fileName)+".")
+	for try := 0; ; try++ {
+		file, err := os.OpenFile(prefix+strconv.FormatUint(uint64(rand.Uint32()), 10), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return file, err
+	}
+}
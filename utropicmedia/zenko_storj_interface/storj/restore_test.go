@@ -0,0 +1,118 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+func TestRestoreToDirFileMode(t *testing.T) {
+	ctx := context.Background()
+	bucket := memory.NewBucket()
+	bucket.PutObject("run/a/0.txt", []byte("hello "), storj.ObjectMetadata{})
+	bucket.PutObject("run/a/1.txt", []byte("world"), storj.ObjectMetadata{})
+	objects := []storj.ChunkedObject{{Key: "a.txt", Chunks: []string{"run/a/0.txt", "run/a/1.txt"}, Size: 11}}
+
+	root, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(root) }()
+	if err := storj.RestoreToDir(ctx, bucket, objects, root, storj.ExistingFail, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(root, "a.txt")
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("restored %q, want %q", data, "hello world")
+	}
+
+	// Compare with a file created by the OS with the same mode, which applies the umask.
+	reference := filepath.Join(root, "reference")
+	if err := ioutil.WriteFile(reference, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.Stat(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != want.Mode().Perm() {
+		t.Errorf("restored file has mode %v, want %v", info.Mode().Perm(), want.Mode().Perm())
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	bucket := memory.NewBucket()
+	bucket.PutObject("run/a/0.txt", []byte("hello "), storj.ObjectMetadata{})
+	bucket.PutObject("run/a/1.txt", []byte("world"), storj.ObjectMetadata{})
+	metadata := storj.ObjectMetadata{ContentType: "text/plain", Tags: map[string]string{"color": "blue"}}
+	objects := []storj.ChunkedObject{
+		{Key: "a.txt", Chunks: []string{"run/a/0.txt", "run/a/1.txt"}, Size: 11, Metadata: metadata},
+		{Key: "gone.txt", DeleteMarker: true, VersionID: "2"},
+	}
+
+	target := memory.NewBucket()
+	target.PutObject("gone.txt", []byte("deleted later"), storj.ObjectMetadata{})
+	if err := storj.Restore(ctx, bucket, objects, target, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	object, ok := target.Object("a.txt")
+	if !ok {
+		t.Fatal("a.txt was not restored")
+	}
+	if string(object.Data) != "hello world" {
+		t.Errorf("restored %q, want %q", object.Data, "hello world")
+	}
+	if object.Metadata.ContentType != "text/plain" || object.Metadata.Tags["color"] != "blue" {
+		t.Errorf("restored with metadata %+v, want %+v", object.Metadata, metadata)
+	}
+	if _, ok := target.Object("gone.txt"); ok {
+		t.Error("the delete marker did not delete gone.txt")
+	}
+}
+
+func TestListChunkedObjects(t *testing.T) {
+	bucket := memory.NewBucket()
+	// Chunks below the escaped key.
+	for _, key := range []string{"a", "a.a", "dir/a b%.txt"} {
+		prefix := "run/" + storj.ChunkPrefix(key) + "/"
+		bucket.PutObject(prefix+storj.ChunkName(0, ""), []byte(key), storj.ObjectMetadata{})
+		bucket.PutObject(prefix+storj.ChunkName(1, ""), []byte("!"), storj.ObjectMetadata{})
+	}
+	// Chunks of "old.txt" and "old.md" uploaded by older versions below the folder "old".
+	bucket.PutObject("run/old/0.txt", []byte("text"), storj.ObjectMetadata{})
+	bucket.PutObject("run/old/0.md", []byte("mark"), storj.ObjectMetadata{})
+	bucket.PutObject("run/old/1.md", []byte("down"), storj.ObjectMetadata{})
+
+	objects, err := storj.ListChunkedObjects(context.Background(), bucket, "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int64)
+	for _, object := range objects {
+		got[object.Key] = object.Size
+	}
+	want := map[string]int64{"a": 2, "a.a": 4, "dir/a b%.txt": 13, "old.txt": 4, "old.md": 8}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/filter"
+)
+
+// Snapshot is the backup of one Zenko bucket made by a single run of the store command.
+type Snapshot struct {
+	// ID is the name of the snapshot folder, "<zenkoBucket>_<time>".
+	ID string
+	// Prefix is the full Storj path of the snapshot folder, with a trailing slash.
+	Prefix string
+	// Bucket is the Zenko bucket the snapshot was taken from.
+	Bucket string
+	// Time is the start time of the run that created the snapshot.
+	Time time.Time
+	// Filter holds the rules that selected the objects of the snapshot, nil when it covers everything.
+	Filter *filter.Config
+	// Versions is set when the snapshot holds every version of the objects.
+	Versions bool
+	// Dir is the local directory the files of the snapshot were read from, "" for a Zenko bucket.
+	Dir string
+	// Manifest is the manifest of the run that created the snapshot, nil for runs made before
+	// manifests existed. SnapshotObjects and SnapshotSummary read the objects from it.
+	Manifest *Manifest
+}
+
+// RunID returns the identifier shared by all snapshots of the same store run.
+func (snapshot Snapshot) RunID() string {
+	return snapshot.Time.Format(SnapshotTimeFormat)
+}
+
+// ListSnapshots returns the snapshots stored below the upload path, ordered by time and Zenko bucket.
+// Snapshots are found from the run manifests and from the snapshot folders, so that both runs
+// whose objects were all referenced from earlier runs and runs made before manifests existed are listed.
+// Every manifest is downloaded once and kept in Snapshot.Manifest.
+func ListSnapshots(ctx context.Context, bucket Destination, uploadPath string) ([]Snapshot, error) {
+	uploadPrefix := UploadPrefix(uploadPath)
+	items, err := bucket.List(ctx, uploadPrefix, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	manifests := make(map[string]*Manifest)
+	for _, item := range items {
+		if item.IsPrefix {
+			ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(item.Path, uploadPrefix), "/"))
+		}
+	}
+
+	runIDs, err := ListManifests(ctx, bucket, uploadPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, runID := range runIDs {
+		manifest, err := downloadManifest(ctx, bucket, uploadPath, runID)
+		if err != nil {
+			return nil, err
+		}
+		for _, zenkoBucket := range manifest.Buckets {
+			ids = append(ids, zenkoBucket+"_"+runID)
+		}
+		manifests[runID] = manifest
+	}
+
+	seen := make(map[string]bool)
+	var snapshots []Snapshot
+	for _, id := range ids {
+		zenkoBucket, timestamp, ok := ParseSnapshotName(id)
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		snapshot := Snapshot{
+			ID:     id,
+			Prefix: uploadPrefix + id + "/",
+			Bucket: zenkoBucket,
+			Time:   timestamp,
+		}
+		if manifest := manifests[snapshot.RunID()]; manifest != nil {
+			snapshot.Filter = manifest.Filter
+			snapshot.Versions = manifest.Versions
+			snapshot.Dir = manifest.Dir
+			snapshot.Manifest = manifest
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].Time.Equal(snapshots[j].Time) {
+			return snapshots[i].Time.Before(snapshots[j].Time)
+		}
+		return snapshots[i].Bucket < snapshots[j].Bucket
+	})
+	return snapshots, nil
+}
+
+// SelectSnapshots returns the snapshots matching id. The id is either a snapshot ID
+// ("<zenkoBucket>_<time>"), which selects one Zenko bucket, or a run ID ("<time>"),
+// which selects every bucket backed up by that run.
+func SelectSnapshots(snapshots []Snapshot, id string) []Snapshot {
+	id = strings.TrimSuffix(id, "/")
+	var selected []Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id || snapshot.RunID() == id {
+			selected = append(selected, snapshot)
+		}
+	}
+	return selected
+}
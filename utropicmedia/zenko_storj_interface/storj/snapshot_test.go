@@ -0,0 +1,65 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"utropicmedia/zenko_storj_interface/memory"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+func TestListSnapshotsLoadsManifestsOnce(t *testing.T) {
+	ctx := context.Background()
+	bucket := memory.NewBucket()
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		runTime := start.Add(time.Duration(i) * time.Hour)
+		manifest := &storj.Manifest{
+			RunID:   runTime.Format(storj.SnapshotTimeFormat),
+			Start:   runTime,
+			Buckets: []string{"docs", "photos"},
+			Objects: []storj.ManifestEntry{{Bucket: "photos", Key: "a.jpg", Size: 10, Chunking: "none", Chunks: 1}},
+		}
+		if err := storj.SaveManifest(ctx, bucket, "backup", manifest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	bucket.SetFault(func(op string, path string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if op == "list" || strings.Contains(path, storj.ManifestFolder+"/") {
+			calls[op]++
+		}
+		return nil
+	})
+
+	// The snapshots command lists the snapshots and sums up every one of them.
+	snapshots, err := storj.ListSnapshots(ctx, bucket, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 10 {
+		t.Fatalf("listed %d snapshots, want 10", len(snapshots))
+	}
+	for _, snapshot := range snapshots {
+		count, size, err := storj.SnapshotSummary(ctx, bucket, snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snapshot.Bucket == "photos" && (count != 1 || size != 10) {
+			t.Errorf("%s holds %d objects of %d bytes, want 1 of 10", snapshot.ID, count, size)
+		}
+	}
+	if calls["get"] != 5 || calls["list"] != 2 {
+		t.Errorf("downloaded %d manifests with %d listings, want 5 with 2", calls["get"], calls["list"])
+	}
+}
@@ -9,10 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"os"
-	"path/filepath"
 	"strconv"
 
 	"storj.io/storj/lib/uplink"
@@ -48,7 +45,9 @@ func LoadStorjConfiguration(fullFileName string) (ConfigStorj, error) { // fullF
 	defer fileHandle.Close()
 
 	jsonParser := json.NewDecoder(fileHandle)
-	jsonParser.Decode(&configStorj)
+	if err := jsonParser.Decode(&configStorj); err != nil {
+		return configStorj, fmt.Errorf("could not parse %s: %v", fullFileName, err)
+	}
 
 	return configStorj, nil
 }
@@ -56,12 +55,23 @@ func LoadStorjConfiguration(fullFileName string) (ConfigStorj, error) { // fullF
 // ConnectStorjReadUploadData reads Storj configuration from given json file,
 // connects to the desired Storj network.
 // It then reads data property from an external config file.
-func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict string) (context.Context, *uplink.Uplink, *uplink.Project, *uplink.Bucket, ConfigStorj, string, error) {
+// With deriveScope, the scope is derived from the API key and the encryption passphrase
+// instead of read from serializedScope, and returned serialized; restrict restricts the
+// returned scope to the bucket, the upload path and the disallow settings.
+// The bucket is created when it does not exist yet.
+// Nothing is left open when it returns an error.
+func ConnectStorjReadUploadData(fullFileName string, deriveScope bool, restrict bool) (context.Context, *uplink.Uplink, *uplink.Project, *uplink.Bucket, ConfigStorj, string, error) {
+	return ConnectStorj(fullFileName, deriveScope, restrict, true)
+}
+
+// ConnectStorj is ConnectStorjReadUploadData, creating the bucket only if createBucket is set.
+func ConnectStorj(fullFileName string, deriveScope bool, restrict bool, createBucket bool) (context.Context, *uplink.Uplink, *uplink.Project, *uplink.Bucket, ConfigStorj, string, error) {
 	// fullFileName for fetching storj V3 credentials from  given JSON filename
 	var scope string
+	ctx := context.Background()
 	configStorj, err := LoadStorjConfiguration(fullFileName)
 	if err != nil {
-		fmt.Printf("LoadStorjConfiguration: %s", err)
+		return ctx, nil, nil, nil, configStorj, "", fmt.Errorf("LoadStorjConfiguration: %v", err)
 	}
 
 	// Display the read information.
@@ -78,22 +88,24 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 	// Configure the user agent
 	cfg.Volatile.UserAgent = "Zenko"
 
-	ctx := context.Background()
+	// fail closes whatever was opened and returns err.
+	fail := func(uplinkstorj *uplink.Uplink, proj *uplink.Project, bucket *uplink.Bucket, message string, err error) (context.Context, *uplink.Uplink, *uplink.Project, *uplink.Bucket, ConfigStorj, string, error) {
+		CloseProject(uplinkstorj, proj, bucket)
+		return ctx, nil, nil, nil, configStorj, "", fmt.Errorf("%s: %v", message, err)
+	}
 
 	uplinkstorj, err := uplink.NewUplink(ctx, &cfg)
 	if err != nil {
-		uplinkstorj.Close()
-		log.Fatal("Could not create new Uplink object:", err)
+		return fail(nil, nil, nil, "could not create new Uplink object", err)
 	}
 
 	var serializedScope string
 
-	if keyValue == "key" {
+	if deriveScope {
 		fmt.Println("Parsing the API key...")
 		key, err := uplink.ParseAPIKey(configStorj.APIKey)
 		if err != nil {
-			uplinkstorj.Close()
-			log.Fatal("Could not parse API key:", err)
+			return fail(uplinkstorj, nil, nil, "could not parse API key", err)
 		}
 
 		if DEBUG {
@@ -105,8 +117,7 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 		proj, err := uplinkstorj.OpenProject(ctx, configStorj.Satellite, key)
 
 		if err != nil {
-			CloseProject(uplinkstorj, proj, nil)
-			log.Fatal("Could not open project:", err)
+			return fail(uplinkstorj, nil, nil, "could not open project", err)
 		}
 
 		// Creating an encryption key from encryption passphrase.
@@ -116,8 +127,7 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 
 		encryptionKey, err := proj.SaltedKeyFromPassphrase(ctx, configStorj.EncryptionPassphrase)
 		if err != nil {
-			CloseProject(uplinkstorj, proj, nil)
-			log.Fatal("Could not create encryption key:", err)
+			return fail(uplinkstorj, proj, nil, "could not create encryption key", err)
 		}
 
 		// Creating an encryption context.
@@ -128,9 +138,7 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 		// Serializing the parsed access, so as to compare with the original key.
 		serializedAccess, err := access.Serialize()
 		if err != nil {
-			CloseProject(uplinkstorj, proj, nil)
-			log.Fatal("Error Serialized key : ", err)
-
+			return fail(uplinkstorj, proj, nil, "could not serialize encryption access", err)
 		}
 		if DEBUG {
 			fmt.Println("Serialized access key\t:", serializedAccess)
@@ -139,10 +147,10 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 		// Load the existing encryption access context
 		accessParse, err := uplink.ParseEncryptionAccess(serializedAccess)
 		if err != nil {
-			log.Fatal(err)
+			return fail(uplinkstorj, proj, nil, "could not parse encryption access", err)
 		}
 
-		if restrict == "restrict" {
+		if restrict {
 			disallowRead, _ := strconv.ParseBool(configStorj.DisallowReads)
 			disallowWrite, _ := strconv.ParseBool(configStorj.DisallowWrites)
 			disallowDelete, _ := strconv.ParseBool(configStorj.DisallowDeletes)
@@ -152,7 +160,7 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 				DisallowDeletes: disallowDelete,
 			})
 			if err != nil {
-				log.Fatal(err)
+				return fail(uplinkstorj, proj, nil, "could not restrict API key", err)
 			}
 
 			userAPIKey, userAccess, err := accessParse.Restrict(userAPIKey,
@@ -162,7 +170,7 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 				},
 			)
 			if err != nil {
-				log.Fatal(err)
+				return fail(uplinkstorj, proj, nil, "could not restrict encryption access", err)
 			}
 			userRestrictScope := &uplink.Scope{
 				SatelliteAddr:    configStorj.Satellite,
@@ -171,7 +179,7 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 			}
 			serializedRestrictScope, err := userRestrictScope.Serialize()
 			if err != nil {
-				log.Fatal(err)
+				return fail(uplinkstorj, proj, nil, "could not serialize restricted scope", err)
 			}
 			scope = serializedRestrictScope
 		}
@@ -183,9 +191,9 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 		}
 		serializedScope, err = userScope.Serialize()
 		if err != nil {
-			log.Fatal(err)
+			return fail(uplinkstorj, proj, nil, "could not serialize scope", err)
 		}
-		if restrict == "" {
+		if !restrict {
 			scope = serializedScope
 		}
 
@@ -197,38 +205,37 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 	}
 	parsedScope, err := uplink.ParseScope(serializedScope)
 	if err != nil {
-		log.Fatal(err)
+		return fail(nil, nil, nil, "could not parse scope", err)
 	}
 
 	uplinkstorj, err = uplink.NewUplink(ctx, &cfg)
 	if err != nil {
-		log.Fatal("Could not create new Uplink object:", err)
+		return fail(nil, nil, nil, "could not create new Uplink object", err)
 	}
 	proj, err := uplinkstorj.OpenProject(ctx, parsedScope.SatelliteAddr, parsedScope.APIKey)
 	if err != nil {
-		CloseProject(uplinkstorj, proj, nil)
-		log.Fatal("Could not open project:", err)
+		return fail(uplinkstorj, nil, nil, "could not open project", err)
 	}
 
 	fmt.Println("Opening Bucket\t\t: ", configStorj.Bucket)
 
 	// Open up the desired Bucket within the Project.
 	bucket, err := proj.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
+	if err != nil && !createBucket {
+		return fail(uplinkstorj, proj, nil, fmt.Sprintf("could not open bucket %q", configStorj.Bucket), err)
+	}
 	if err != nil {
 		fmt.Println("Could not open bucket", configStorj.Bucket, ":", err)
 		fmt.Println("Trying to create new bucket....")
 		_, err1 := proj.CreateBucket(ctx, configStorj.Bucket, nil)
 		if err1 != nil {
-			CloseProject(uplinkstorj, proj, bucket)
-			fmt.Printf("Could not create bucket %q:", configStorj.Bucket)
-			log.Fatal(err1)
-		} else {
-			fmt.Println("Created Bucket", configStorj.Bucket)
+			return fail(uplinkstorj, proj, nil, fmt.Sprintf("could not create bucket %q", configStorj.Bucket), err1)
 		}
+		fmt.Println("Created Bucket", configStorj.Bucket)
 		fmt.Println("Opening created Bucket: ", configStorj.Bucket)
 		bucket, err = proj.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
 		if err != nil {
-			fmt.Printf("Could not open bucket %q: %s", configStorj.Bucket, err)
+			return fail(uplinkstorj, proj, nil, fmt.Sprintf("could not open bucket %q", configStorj.Bucket), err)
 		}
 	}
 	return ctx, uplinkstorj, proj, bucket, configStorj, scope, err
@@ -236,7 +243,7 @@ func ConnectStorjReadUploadData(fullFileName string, keyValue string, restrict s
 
 // ConnectUpload uploads the data to storj network.
 // Read data using io.Reader and upload it to Storj.
-func ConnectUpload(ctx context.Context, bucket *uplink.Bucket, data []byte, filename string, fileNamesDEBUG []string, configStorj ConfigStorj, err error) []string {
+func ConnectUpload(ctx context.Context, bucket Destination, data []byte, filename string, fileNamesDEBUG []string, configStorj ConfigStorj) ([]string, error) {
 
 	checkSlash := configStorj.UploadPath[len(configStorj.UploadPath)-1:]
 	if checkSlash != "/" {
@@ -248,9 +255,9 @@ func ConnectUpload(ctx context.Context, bucket *uplink.Bucket, data []byte, file
 	readerBytes := bytes.NewReader(data)
 	readerIO := io.Reader(readerBytes)
 	// Upload the data on storj.
-	err = bucket.UploadObject(ctx, configStorj.UploadPath+filename, readerIO, nil)
+	err := bucket.Put(ctx, configStorj.UploadPath+filename, readerIO, int64(len(data)), ObjectMetadata{})
 	if err != nil {
-		log.Fatal("Could not upload:", err)
+		return fileNamesDEBUG, fmt.Errorf("could not upload: %v", err)
 	}
 	if DEBUG {
 		fileNamesDEBUG = append(fileNamesDEBUG, filename)
@@ -258,54 +265,24 @@ func ConnectUpload(ctx context.Context, bucket *uplink.Bucket, data []byte, file
 
 	fmt.Println("Uploading object to Storj bucket: Completed!")
 
-	return fileNamesDEBUG
+	return fileNamesDEBUG, nil
 }
 
-// Debug function downloads the data from StorJ bucket to verify data from Zenko is uploaded successfully.
-func Debug(ctx context.Context, bucket *uplink.Bucket, uploadPath string, fileName []string, fileExt []string) {
-	if DEBUG {
+// UploadStream uploads everything read from reader as a single object to the storj network,
+// with the given metadata.
+func UploadStream(ctx context.Context, bucket Destination, reader io.Reader, filename string, configStorj ConfigStorj, metadata ObjectMetadata) error {
+	uploadPath := UploadPrefix(configStorj.UploadPath)
 
-		checkSlash := uploadPath[len(uploadPath)-1:]
-		if checkSlash != "/" {
-			uploadPath = uploadPath + "/"
-		}
-		for i := 0; i < len(fileName); i++ {
-			fmt.Printf("\n\nDownloading Object %s from bucket : Initiated...\n", uploadPath+fileName[i]+"/")
-			list, err := bucket.ListObjects(ctx, &uplink.ListOptions{
-				Direction: 2,
-				Cursor:    "",
-				Prefix:    uploadPath + fileName[i] + "/",
-				Recursive: false,
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
-			for j := 0; j < len(list.Items); j++ {
-				//for _, object := range list.Items {
-				strm, err := bucket.Download(ctx, uploadPath+fileName[i]+"/"+strconv.Itoa(j)+"."+fileExt[i])
-				if err != nil {
-					fmt.Printf("Could not open object at %q: %v", strconv.Itoa(j)+"."+fileExt[i], err)
-				}
-				defer strm.Close()
-				// Read everything from the stream.
-				receivedContents, err := ioutil.ReadAll(strm)
-				if err != nil {
-					fmt.Printf("Could not read object: %v", err)
-				}
-				filePath := filepath.Dir(fileName[i])
-
-				_ = os.MkdirAll("debug/"+uploadPath+filePath+"/", 0755) //Make directory on system
-				var fileNameDownload = filepath.Join("debug", uploadPath+fileName[i]+"."+fileExt[i])
-				f, err := os.OpenFile(fileNameDownload, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
-				_, err = f.Write(receivedContents) //Append the bytes to the file created
-				if err != nil {
-					fmt.Println(err)
-				}
-
-				fmt.Printf("%d bytes of Object from bucket!\n", len(receivedContents))
-			}
-		}
+	fmt.Println("\nUpload Object Path: ", uploadPath+filename)
+
+	// Upload the data on storj.
+	err := bucket.Put(ctx, uploadPath+filename, reader, -1, metadata)
+	if err != nil {
+		return err
 	}
+
+	fmt.Println("Uploading object to Storj bucket: Completed!")
+	return nil
 }
 
 // CloseProject closes bucket, project and uplink.
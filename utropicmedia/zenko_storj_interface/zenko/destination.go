@@ -0,0 +1,104 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package zenko
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+// Destination writes objects to one bucket of a Zenko instance, or of any other S3 service.
+// It implements storj.Destination; the paths are the object keys.
+type Destination struct {
+	Client *minio.Client
+	// Tagger writes and reads the tags of the objects, nil to leave tags alone.
+	Tagger *Tagger
+	// Bucket is the bucket the objects are written to.
+	Bucket string
+}
+
+var _ storj.Destination = (*Destination)(nil)
+
+// Put implements storj.Destination. The metadata is written as HTTP headers and
+// user metadata, and the tags, if any, with a request of their own.
+func (destination *Destination) Put(ctx context.Context, path string, data io.Reader, size int64, metadata storj.ObjectMetadata) error {
+	_, err := destination.Client.PutObjectWithContext(ctx, destination.Bucket, path, data, size, PutObjectOptions(metadata))
+	if err != nil {
+		return err
+	}
+	if len(metadata.Tags) > 0 && destination.Tagger != nil {
+		return destination.Tagger.PutTags(ctx, destination.Bucket, path, metadata.Tags)
+	}
+	return nil
+}
+
+// List implements storj.Destination.
+func (destination *Destination) List(ctx context.Context, prefix string, recursive bool) ([]storj.ObjectInfo, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	var objects []storj.ObjectInfo
+	for object := range destination.Client.ListObjectsV2(destination.Bucket, prefix, recursive, done) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		objects = append(objects, storj.ObjectInfo{
+			Path:     object.Key,
+			IsPrefix: !recursive && strings.HasSuffix(object.Key, "/"),
+			Size:     object.Size,
+			Modified: object.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+// Get implements storj.Destination. A missing key is reported as storj.ErrNotFound.
+// The data is only requested when it is first read.
+func (destination *Destination) Get(ctx context.Context, path string, offset int64) (storj.ObjectInfo, io.ReadCloser, error) {
+	objectInfo, err := destination.Client.StatObject(destination.Bucket, path, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return storj.ObjectInfo{}, nil, fmt.Errorf("%s/%s: %w", destination.Bucket, path, storj.ErrNotFound)
+	}
+	if err != nil {
+		return storj.ObjectInfo{}, nil, err
+	}
+	info := storj.ObjectInfo{
+		Path:     path,
+		Size:     objectInfo.Size,
+		Modified: objectInfo.LastModified,
+		Metadata: Metadata(objectInfo),
+	}
+	if destination.Tagger != nil {
+		info.Metadata.Tags, err = destination.Tagger.GetTags(ctx, destination.Bucket, path, "")
+		if err != nil {
+			return storj.ObjectInfo{}, nil, err
+		}
+	}
+
+	var options minio.GetObjectOptions
+	if offset > 0 {
+		if err := options.SetRange(offset, 0); err != nil {
+			return storj.ObjectInfo{}, nil, err
+		}
+	}
+	object, err := destination.Client.GetObjectWithContext(ctx, destination.Bucket, path, options)
+	if err != nil {
+		return storj.ObjectInfo{}, nil, err
+	}
+	return info, object, nil
+}
+
+// Delete implements storj.Destination.
+func (destination *Destination) Delete(ctx context.Context, path string) error {
+	return destination.Client.RemoveObject(destination.Bucket, path)
+}
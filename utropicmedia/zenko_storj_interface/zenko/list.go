@@ -0,0 +1,62 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package zenko
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/retry"
+)
+
+// listPageSize is the number of keys requested per listing request.
+const listPageSize = 1000
+
+// ListObjects calls fn for every object of the bucket selected by keys, in key order.
+// Only the prefixes keys includes are listed, and the listing jumps over excluded
+// prefixes, so the objects below them are never read. A failed listing request is
+// retried with policy and continues after the last key listed.
+func ListObjects(ctx context.Context, client *minio.Client, bucket string, keys *filter.Filter, policy *retry.Policy, fn func(minio.ObjectInfo) error) error {
+	core := minio.Core{Client: client}
+	for _, prefix := range keys.ListPrefixes() {
+		var marker string
+		for {
+			var result minio.ListBucketResult
+			err := policy.Do(ctx, "list "+bucket+"/"+prefix, func() (err error) {
+				result, err = core.ListObjects(bucket, prefix, marker, "", listPageSize)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			jumped := false
+			for _, object := range result.Contents {
+				if excluded := keys.ExcludedPrefix(object.Key); excluded != "" {
+					// Continue after the last key any object below the prefix can have.
+					marker = excluded + string(utf8.MaxRune)
+					jumped = true
+					break
+				}
+				marker = object.Key
+				if !keys.Object(object.Key, object.Size, object.LastModified) {
+					continue
+				}
+				if err := fn(object); err != nil {
+					return err
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !jumped && (!result.IsTruncated || len(result.Contents) == 0) {
+				break
+			}
+		}
+	}
+	return nil
+}
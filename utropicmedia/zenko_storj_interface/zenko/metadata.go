@@ -0,0 +1,47 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package zenko
+
+import (
+	"net/textproto"
+	"strings"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+// Metadata returns the metadata of a Zenko object from the result of StatObject.
+func Metadata(info minio.ObjectInfo) storj.ObjectMetadata {
+	metadata := storj.ObjectMetadata{
+		ContentType:        info.ContentType,
+		CacheControl:       info.Metadata.Get("Cache-Control"),
+		ContentDisposition: info.Metadata.Get("Content-Disposition"),
+		ContentEncoding:    info.Metadata.Get("Content-Encoding"),
+		ContentLanguage:    info.Metadata.Get("Content-Language"),
+	}
+	for name, values := range info.Metadata {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		if !strings.HasPrefix(name, storj.UserMetadataPrefix) || len(values) == 0 {
+			continue
+		}
+		if metadata.UserMetadata == nil {
+			metadata.UserMetadata = make(map[string]string)
+		}
+		metadata.UserMetadata[strings.TrimPrefix(name, storj.UserMetadataPrefix)] = values[0]
+	}
+	return metadata
+}
+
+// PutObjectOptions returns the options that write the metadata back to a Zenko object.
+func PutObjectOptions(metadata storj.ObjectMetadata) minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		ContentType:        metadata.ContentType,
+		CacheControl:       metadata.CacheControl,
+		ContentDisposition: metadata.ContentDisposition,
+		ContentEncoding:    metadata.ContentEncoding,
+		ContentLanguage:    metadata.ContentLanguage,
+		UserMetadata:       metadata.UserMetadata,
+	}
+}
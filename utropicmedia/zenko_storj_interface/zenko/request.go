@@ -0,0 +1,134 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package zenko
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/s3signer"
+	"github.com/minio/minio-go/pkg/s3utils"
+)
+
+// DefaultRegion is the region requests are signed for.
+const DefaultRegion = "us-east-1"
+
+// signer sends the signed S3 requests the minio client has no API for.
+// They are addressed and signed like the requests of the minio client.
+type signer struct {
+	endpoint     *url.URL
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	signatureV2  bool
+	bucketLookup string
+	client       *http.Client
+}
+
+// newSigner returns a signer for the S3 service of the configuration.
+func newSigner(configS3 ConfigS3) (*signer, error) {
+	scheme := "https://"
+	if configS3.DisableTLS {
+		scheme = "http://"
+	}
+	endpoint, err := url.Parse(scheme + configS3.EndPoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: %v", configS3.EndPoint, err)
+	}
+	region := configS3.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	return &signer{
+		endpoint:     endpoint,
+		accessKey:    configS3.AccessKeyID,
+		secretKey:    configS3.SecretAccessKey,
+		sessionToken: configS3.SessionToken,
+		region:       region,
+		signatureV2:  configS3.SignatureVersion == SignatureV2,
+		bucketLookup: configS3.BucketLookup,
+		client:       &http.Client{Transport: http.DefaultTransport},
+	}, nil
+}
+
+// virtualHost reports whether requests for the bucket are sent to "<bucket>.<endpoint>".
+// Like the minio client, the auto style only does so for Amazon S3, and for bucket names
+// without dots, which TLS certificates of the form *.<endpoint> do not cover.
+func (signer *signer) virtualHost(bucket string) bool {
+	switch signer.bucketLookup {
+	case LookupDNS:
+		return true
+	case LookupPath:
+		return false
+	}
+	return strings.HasSuffix(signer.endpoint.Hostname(), "amazonaws.com") && !strings.Contains(bucket, ".")
+}
+
+// do sends a signed request for the object key of the bucket, or for the bucket itself when
+// key is empty. The query selects the subresource; header is added to the request.
+func (signer *signer) do(ctx context.Context, method string, bucket string, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	target := *signer.endpoint
+	virtualHost := signer.virtualHost(bucket)
+	if virtualHost {
+		target.Host = bucket + "." + target.Host
+		target.Path = "/" + key
+		target.RawPath = "/" + s3utils.EncodePath(key)
+	} else {
+		target.Path = "/" + bucket + "/" + key
+		target.RawPath = "/" + bucket + "/" + s3utils.EncodePath(key)
+	}
+	target.RawQuery = s3utils.QueryEncode(query)
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	if body != nil {
+		md5Sum := md5.Sum(body)
+		req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+		req.Header.Set("Content-Type", "application/xml")
+		req.ContentLength = int64(len(body))
+	}
+	if signer.signatureV2 {
+		if signer.sessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", signer.sessionToken)
+		}
+		req = s3signer.SignV2(*req, signer.accessKey, signer.secretKey, virtualHost)
+	} else {
+		req = s3signer.SignV4(*req, signer.accessKey, signer.secretKey, signer.sessionToken, signer.region)
+	}
+	return signer.client.Do(req)
+}
+
+// errorResponse decodes an S3 error, so that it is classified like the errors of the minio client.
+func errorResponse(resp *http.Response, data []byte, bucket string, key string) error {
+	response := minio.ErrorResponse{
+		StatusCode: resp.StatusCode,
+		BucketName: bucket,
+		Key:        key,
+	}
+	if err := xml.Unmarshal(data, &response); err != nil || response.Code == "" {
+		response.Code = resp.Status
+		response.Message = http.StatusText(resp.StatusCode)
+	}
+	response.StatusCode = resp.StatusCode
+	return response
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package zenko
+
+import (
+	"context"
+	"io"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/retry"
+	"utropicmedia/zenko_storj_interface/storj"
+)
+
+// Source reads the objects of the buckets of a Zenko instance, or of any other S3 service,
+// for the store and sync commands.
+type Source struct {
+	Client *minio.Client
+	// Tagger reads the tags of the objects, nil to not read tags.
+	Tagger *Tagger
+	// Versions lists and reads every version and delete marker of the objects,
+	// nil to read only their current versions.
+	Versions *VersionReader
+	// Retry is the policy failed listing requests are retried with.
+	Retry *retry.Policy
+}
+
+// List calls fn for every object of the bucket selected by keys, as ListObjects does,
+// or for every version and delete marker of them, as ListObjectVersions does, when
+// Source.Versions is set.
+func (source *Source) List(ctx context.Context, bucket string, keys *filter.Filter, fn func(ObjectVersion) error) error {
+	if source.Versions != nil {
+		return source.Versions.ListObjectVersions(ctx, bucket, keys, source.Retry, fn)
+	}
+	return ListObjects(ctx, source.Client, bucket, keys, source.Retry, func(object minio.ObjectInfo) error {
+		return fn(ObjectVersion{ObjectInfo: object})
+	})
+}
+
+// Stat returns the metadata of an object, which is not part of the listing, and its tags.
+func (source *Source) Stat(ctx context.Context, bucket string, object ObjectVersion) (storj.ObjectMetadata, error) {
+	var objectInfo minio.ObjectInfo
+	var err error
+	if object.VersionID != "" {
+		objectInfo, err = source.Versions.StatVersion(ctx, bucket, object.Key, object.VersionID)
+	} else {
+		objectInfo, err = source.Client.StatObject(bucket, object.Key, minio.StatObjectOptions{})
+	}
+	if err != nil {
+		return storj.ObjectMetadata{}, err
+	}
+	metadata := Metadata(objectInfo)
+	if source.Tagger != nil {
+		metadata.Tags, err = source.Tagger.GetTags(ctx, bucket, object.Key, object.VersionID)
+		if err != nil {
+			return storj.ObjectMetadata{}, err
+		}
+	}
+	return metadata, nil
+}
+
+// Open returns the data of an object starting at offset.
+func (source *Source) Open(ctx context.Context, bucket string, object ObjectVersion, offset int64) (io.ReadCloser, error) {
+	if object.VersionID != "" {
+		return source.Versions.OpenVersion(ctx, bucket, object.Key, object.VersionID, offset)
+	}
+	objectReader, err := source.Client.GetObjectWithContext(ctx, bucket, object.Key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := objectReader.Seek(offset, io.SeekStart); err != nil {
+			_ = objectReader.Close()
+			return nil, err
+		}
+	}
+	return objectReader, nil
+}
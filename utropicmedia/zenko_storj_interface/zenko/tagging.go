@@ -0,0 +1,116 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package zenko
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/minio/minio-go"
+)
+
+// Tagger reads and writes the tag sets of Zenko objects. The minio client does not
+// support object tagging, so it sends signed S3 requests of its own.
+type Tagger struct {
+	signer *signer
+
+	unsupported sync.Once
+}
+
+// tagging is the XML document of an object's tag set.
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Tags    []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// NewTagger returns a Tagger for the S3 service of the configuration.
+func NewTagger(configS3 ConfigS3) (*Tagger, error) {
+	signer, err := newSigner(configS3)
+	if err != nil {
+		return nil, err
+	}
+	return &Tagger{signer: signer}, nil
+}
+
+// GetTags returns the tag set of an object, or of one of its versions when versionID is set.
+// Objects without tags, and Zenko instances that do not support tagging, return no tags.
+func (tagger *Tagger) GetTags(ctx context.Context, bucket string, key string, versionID string) (map[string]string, error) {
+	query := url.Values{"tagging": {""}}
+	if versionID != "" {
+		query.Set("versionId", versionID)
+	}
+	resp, err := tagger.signer.do(ctx, http.MethodGet, bucket, key, query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := errorResponse(resp, data, bucket, key)
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchTagSet":
+			return nil, nil
+		case "NotImplemented":
+			tagger.unsupported.Do(func() {
+				fmt.Println("Zenko does not support object tagging, tags are not backed up.")
+			})
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var document tagging
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("could not parse tags of %s/%s: %v", bucket, key, err)
+	}
+	if len(document.Tags) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(document.Tags))
+	for _, tag := range document.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// PutTags replaces the tag set of an object.
+func (tagger *Tagger) PutTags(ctx context.Context, bucket string, key string, tags map[string]string) error {
+	var document tagging
+	for name, value := range tags {
+		document.Tags = append(document.Tags, tag{Key: name, Value: value})
+	}
+	sort.Slice(document.Tags, func(i, j int) bool { return document.Tags[i].Key < document.Tags[j].Key })
+	body, err := xml.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	resp, err := tagger.signer.do(ctx, http.MethodPut, bucket, key, url.Values{"tagging": {""}}, nil, body)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errorResponse(resp, data, bucket, key)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package zenko
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/minio/minio-go"
+
+	"utropicmedia/zenko_storj_interface/filter"
+	"utropicmedia/zenko_storj_interface/retry"
+)
+
+// ObjectVersion is one version of a Zenko object, or a delete marker.
+type ObjectVersion struct {
+	minio.ObjectInfo
+	// VersionID identifies the version; it is empty for objects listed without versions
+	// and "null" for objects written before versioning was enabled on the bucket.
+	VersionID string
+	// IsLatest is set for the current version of the key.
+	IsLatest bool
+	// DeleteMarker is set when the version records the deletion of the key and has no data.
+	DeleteMarker bool
+}
+
+// VersionReader lists and reads the versions of objects in versioned Zenko buckets.
+// The minio client only sees the current versions, so it sends signed S3 requests of its own.
+type VersionReader struct {
+	signer *signer
+}
+
+// listVersionsResult is the XML document returned by a ListObjectVersions request.
+// Versions and delete markers are kept in one list, in the order of the response.
+type listVersionsResult struct {
+	XMLName             xml.Name       `xml:"ListVersionsResult"`
+	IsTruncated         bool           `xml:"IsTruncated"`
+	NextKeyMarker       string         `xml:"NextKeyMarker"`
+	NextVersionIDMarker string         `xml:"NextVersionIdMarker"`
+	Entries             []versionEntry `xml:",any"`
+}
+
+// versionEntry is a Version or DeleteMarker element of a listVersionsResult.
+type versionEntry struct {
+	XMLName      xml.Name
+	Key          string    `xml:"Key"`
+	VersionID    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+}
+
+// NewVersionReader returns a VersionReader for the S3 service of the configuration.
+func NewVersionReader(configS3 ConfigS3) (*VersionReader, error) {
+	signer, err := newSigner(configS3)
+	if err != nil {
+		return nil, err
+	}
+	return &VersionReader{signer: signer}, nil
+}
+
+// ListObjectVersions calls fn for every version and delete marker of the bucket selected
+// by keys, in key order and, within a key, newest first. Like ListObjects it only lists
+// the included prefixes and jumps over excluded ones. Delete markers have no size, so they
+// are selected by their key only. A failed listing request is retried with policy.
+func (versions *VersionReader) ListObjectVersions(ctx context.Context, bucket string, keys *filter.Filter, policy *retry.Policy, fn func(ObjectVersion) error) error {
+	for _, prefix := range keys.ListPrefixes() {
+		var keyMarker, versionIDMarker string
+		for {
+			var result listVersionsResult
+			err := policy.Do(ctx, "list versions "+bucket+"/"+prefix, func() (err error) {
+				result, err = versions.list(ctx, bucket, prefix, keyMarker, versionIDMarker)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			jumped := false
+			listed := 0
+			for _, entry := range result.Entries {
+				deleteMarker := entry.XMLName.Local == "DeleteMarker"
+				if entry.XMLName.Local != "Version" && !deleteMarker {
+					continue
+				}
+				listed++
+				if excluded := keys.ExcludedPrefix(entry.Key); excluded != "" {
+					// Continue after the last key any object below the prefix can have.
+					keyMarker, versionIDMarker = excluded+string(utf8.MaxRune), ""
+					jumped = true
+					break
+				}
+				version := ObjectVersion{
+					ObjectInfo: minio.ObjectInfo{
+						Key:          entry.Key,
+						Size:         entry.Size,
+						ETag:         strings.Trim(entry.ETag, "\""),
+						LastModified: entry.LastModified,
+					},
+					VersionID:    entry.VersionID,
+					IsLatest:     entry.IsLatest,
+					DeleteMarker: deleteMarker,
+				}
+				if deleteMarker && !keys.Key(entry.Key) || !deleteMarker && !keys.Object(entry.Key, entry.Size, entry.LastModified) {
+					continue
+				}
+				if err := fn(version); err != nil {
+					return err
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if jumped {
+				continue
+			}
+			if !result.IsTruncated || listed == 0 {
+				break
+			}
+			keyMarker, versionIDMarker = result.NextKeyMarker, result.NextVersionIDMarker
+		}
+	}
+	return nil
+}
+
+// list sends a single ListObjectVersions request.
+func (versions *VersionReader) list(ctx context.Context, bucket string, prefix string, keyMarker string, versionIDMarker string) (listVersionsResult, error) {
+	query := url.Values{
+		"versions": {""},
+		"max-keys": {strconv.Itoa(listPageSize)},
+	}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if keyMarker != "" {
+		query.Set("key-marker", keyMarker)
+		if versionIDMarker != "" {
+			query.Set("version-id-marker", versionIDMarker)
+		}
+	}
+
+	var result listVersionsResult
+	resp, err := versions.signer.do(ctx, http.MethodGet, bucket, "", query, nil, nil)
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, errorResponse(resp, data, bucket, "")
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("could not parse versions of bucket %s: %v", bucket, err)
+	}
+	return result, nil
+}
+
+// StatVersion returns the information of one version of an object, like StatObject does
+// for the current version. Its Metadata holds the response headers.
+func (versions *VersionReader) StatVersion(ctx context.Context, bucket string, key string, versionID string) (minio.ObjectInfo, error) {
+	resp, err := versions.signer.do(ctx, http.MethodHead, bucket, key, url.Values{"versionId": {versionID}}, nil, nil)
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		// HEAD responses have no body, the status is all there is.
+		return minio.ObjectInfo{}, errorResponse(resp, nil, bucket, key)
+	}
+
+	lastModified, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return minio.ObjectInfo{}, fmt.Errorf("invalid Last-Modified of %s/%s version %s: %v", bucket, key, versionID, err)
+	}
+	contentType := strings.TrimSpace(resp.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return minio.ObjectInfo{
+		Key:          key,
+		Size:         resp.ContentLength,
+		ETag:         strings.Trim(resp.Header.Get("ETag"), "\""),
+		LastModified: lastModified,
+		ContentType:  contentType,
+		Metadata:     resp.Header,
+	}, nil
+}
+
+// OpenVersion returns the data of one version of an object, starting at offset.
+func (versions *VersionReader) OpenVersion(ctx context.Context, bucket string, key string, versionID string, offset int64) (io.ReadCloser, error) {
+	header := make(http.Header)
+	if offset > 0 {
+		header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := versions.signer.do(ctx, http.MethodGet, bucket, key, url.Values{"versionId": {versionID}}, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer func() { _ = resp.Body.Close() }()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return nil, errorResponse(resp, data, bucket, key)
+	}
+	return resp.Body, nil
+}
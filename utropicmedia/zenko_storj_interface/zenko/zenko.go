@@ -10,42 +10,179 @@ import (
 	"os"
 
 	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
+
+	"utropicmedia/zenko_storj_interface/filter"
 )
 
 // DEBUG allows more detailed working to be exposed through the terminal.
 var DEBUG = false
 
-// ConfigZenko defines the variables and types.
-type ConfigZenko struct {
-	EndPoint        string `json:"zenkoEndpoint"`
+// Source profiles fill in the settings of an S3 provider that are not configured.
+const (
+	// ProfileZenko connects to a Zenko instance; it is the default.
+	ProfileZenko = "zenko"
+	// ProfileAWS connects to Amazon S3, at s3.amazonaws.com unless an endpoint is configured.
+	ProfileAWS = "aws"
+	// ProfileWasabi connects to Wasabi, at s3.wasabisys.com unless an endpoint is configured.
+	ProfileWasabi = "wasabi"
+	// ProfileCeph connects to a Ceph RADOS Gateway, with path-style requests.
+	ProfileCeph = "ceph"
+	// ProfileMinIO connects to a MinIO server, with path-style requests.
+	ProfileMinIO = "minio"
+)
+
+// Addressing styles of ConfigS3.BucketLookup.
+const (
+	// LookupAuto uses virtual-host-style requests for Amazon S3 and path-style requests otherwise.
+	LookupAuto = "auto"
+	// LookupPath sends requests to https://<endpoint>/<bucket>/<key>.
+	LookupPath = "path"
+	// LookupDNS sends virtual-host-style requests to https://<bucket>.<endpoint>/<key>.
+	LookupDNS = "dns"
+)
+
+// Signature versions of ConfigS3.SignatureVersion.
+const (
+	SignatureV4 = "v4"
+	SignatureV2 = "v2"
+)
+
+// ConfigS3 configures the S3 service objects are read from and restored to:
+// Zenko, Amazon S3, Wasabi, Ceph RGW, MinIO or any other S3-compatible service.
+type ConfigS3 struct {
+	// Profile is zenko (default), aws, wasabi, ceph or minio.
+	Profile         string `json:"profile,omitempty"`
+	EndPoint        string `json:"endpoint"`
 	AccessKeyID     string `json:"accessKeyID"`
 	SecretAccessKey string `json:"secretAccessKey"`
+	// SessionToken is the token of temporary credentials.
+	SessionToken string `json:"sessionToken,omitempty"`
+	// Region is the region requests are signed for, us-east-1 when it is empty.
+	Region string `json:"region,omitempty"`
+	// BucketLookup is the addressing style: auto (default), path or dns.
+	BucketLookup string `json:"bucketLookup,omitempty"`
+	// SignatureVersion is v4 (default) or v2.
+	SignatureVersion string `json:"signatureVersion,omitempty"`
+	// DisableTLS sends requests over plain HTTP, for example to a local MinIO.
+	DisableTLS bool `json:"disableTLS,omitempty"`
+	// Filter selects the buckets and keys that are read.
+	Filter filter.Config `json:"filter"`
+}
+
+// ConfigZenko is the former name of ConfigS3.
+type ConfigZenko = ConfigS3
+
+// configFile is the JSON file a ConfigS3 is read from. Files written for
+// earlier versions name the endpoint zenkoEndpoint.
+type configFile struct {
+	ConfigS3
+	ZenkoEndpoint string `json:"zenkoEndpoint"`
+}
+
+// applyProfile fills in the settings of the profile that are not configured and checks the configuration.
+func (config *ConfigS3) applyProfile() error {
+	if config.Profile == "" {
+		config.Profile = ProfileZenko
+	}
+	switch config.Profile {
+	case ProfileZenko:
+	case ProfileAWS:
+		if config.EndPoint == "" {
+			config.EndPoint = "s3.amazonaws.com"
+		}
+	case ProfileWasabi:
+		if config.EndPoint == "" {
+			config.EndPoint = "s3.wasabisys.com"
+		}
+	case ProfileCeph, ProfileMinIO:
+		if config.BucketLookup == "" {
+			config.BucketLookup = LookupPath
+		}
+	default:
+		return fmt.Errorf("unknown profile %q, expected %s, %s, %s, %s or %s", config.Profile, ProfileZenko, ProfileAWS, ProfileWasabi, ProfileCeph, ProfileMinIO)
+	}
+	if config.EndPoint == "" {
+		return fmt.Errorf("no endpoint configured")
+	}
+	if config.BucketLookup == "" {
+		config.BucketLookup = LookupAuto
+	}
+	switch config.BucketLookup {
+	case LookupAuto, LookupPath, LookupDNS:
+	default:
+		return fmt.Errorf("unknown bucketLookup %q, expected %s, %s or %s", config.BucketLookup, LookupAuto, LookupPath, LookupDNS)
+	}
+	if config.SignatureVersion == "" {
+		config.SignatureVersion = SignatureV4
+	}
+	switch config.SignatureVersion {
+	case SignatureV4, SignatureV2:
+	default:
+		return fmt.Errorf("unknown signatureVersion %q, expected %s or %s", config.SignatureVersion, SignatureV4, SignatureV2)
+	}
+	return nil
+}
+
+// Options returns the options the minio client of the configuration is created with.
+func (config ConfigS3) Options() *minio.Options {
+	signerType := credentials.SignatureV4
+	if config.SignatureVersion == SignatureV2 {
+		signerType = credentials.SignatureV2
+	}
+	options := &minio.Options{
+		Creds:  credentials.NewStatic(config.AccessKeyID, config.SecretAccessKey, config.SessionToken, signerType),
+		Secure: !config.DisableTLS,
+		Region: config.Region,
+	}
+	switch config.BucketLookup {
+	case LookupPath:
+		options.BucketLookup = minio.BucketLookupPath
+	case LookupDNS:
+		options.BucketLookup = minio.BucketLookupDNS
+	}
+	return options
 }
 
 // ZenkoReader implements an io.Reader interface
 type ZenkoReader struct {
 	Client *minio.Client
+	Config ConfigS3
+	// Tagger reads and writes object tags.
+	Tagger *Tagger
+	// Versions lists and reads object versions of versioned buckets.
+	Versions *VersionReader
 }
 
 // LoadZenkoProperty reads and parses the JSON file.
 // that contain a Zenko instance's property.
 // and returns all the properties as an object.
-func LoadZenkoProperty(fullFileName string) (ConfigZenko, error) { // fullFileName for fetching Zenko credentials from  given JSON filename.
-	var configZenko ConfigZenko
+func LoadZenkoProperty(fullFileName string) (ConfigS3, error) { // fullFileName for fetching Zenko credentials from  given JSON filename.
+	var file configFile
 	// Open and read the file
 	fileHandle, err := os.Open(fullFileName)
 	if err != nil {
-		return configZenko, err
+		return file.ConfigS3, err
 	}
 	defer fileHandle.Close()
 
 	jsonParser := json.NewDecoder(fileHandle)
-	jsonParser.Decode(&configZenko)
+	if err := jsonParser.Decode(&file); err != nil {
+		return file.ConfigS3, fmt.Errorf("could not parse %s: %v", fullFileName, err)
+	}
+	configS3 := file.ConfigS3
+	if configS3.EndPoint == "" {
+		configS3.EndPoint = file.ZenkoEndpoint
+	}
+	if err := configS3.applyProfile(); err != nil {
+		return configS3, fmt.Errorf("invalid configuration in %s: %v", fullFileName, err)
+	}
 
 	// Display read information.
 	fmt.Println("Read Zenko configuration from the ", fullFileName, " file")
-	fmt.Println("Zenko End Point\t: ", configZenko.EndPoint)
-	return configZenko, nil
+	fmt.Println("S3 Profile\t: ", configS3.Profile)
+	fmt.Println("Zenko End Point\t: ", configS3.EndPoint)
+	return configS3, nil
 }
 
 // ConnectToZenko will connect to a Zenko instance,
@@ -53,7 +190,7 @@ func LoadZenkoProperty(fullFileName string) (ConfigZenko, error) { // fullFileNa
 // It returns a reference to an io.Reader with Zenko instance information
 func ConnectToZenko(fullFileName string) (*ZenkoReader, error) { // fullFileName for fetching Zenko credentials from given JSON filename.
 	// Read Zenko instance's properties from an external file.
-	configZenko, err := LoadZenkoProperty(fullFileName)
+	configS3, err := LoadZenkoProperty(fullFileName)
 
 	if err != nil {
 		log.Printf("Load Zenko Property Error: %s\n", err)
@@ -61,12 +198,29 @@ func ConnectToZenko(fullFileName string) (*ZenkoReader, error) { // fullFileName
 	}
 
 	fmt.Println("\nConnecting to Zenko...")
+	return NewZenkoReader(configS3)
+}
+
+// NewZenkoReader returns the clients of the S3 service of a configuration
+// that has already been checked, such as the one LoadZenkoProperty returns.
+func NewZenkoReader(configS3 ConfigS3) (*ZenkoReader, error) {
 	// Initialize minio client object.
-	minioClient, err := minio.New(configZenko.EndPoint, configZenko.AccessKeyID, configZenko.SecretAccessKey, true)
+	minioClient, err := minio.NewWithOptions(configS3.EndPoint, configS3.Options())
+	if err != nil {
+		return nil, fmt.Errorf("could not create Zenko client: %v", err)
+	}
+
+	// Object tags are read and written with requests of their own.
+	tagger, err := NewTagger(configS3)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	// So are object versions.
+	versions, err := NewVersionReader(configS3)
+	if err != nil {
+		return nil, err
 	}
 
 	// Return Zenko connection client.
-	return &ZenkoReader{Client: minioClient}, nil
+	return &ZenkoReader{Client: minioClient, Config: configS3, Tagger: tagger, Versions: versions}, nil
 }